@@ -3,11 +3,9 @@
 package auth
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math/big"
 	"net"
 	"time"
@@ -15,6 +13,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/util"
 )
 
@@ -23,71 +22,86 @@ var (
 	ErrDupe     = errors.New("auth: duplicate login detected")
 	ErrExists   = errors.New("auth: username already exists")
 	ErrNotSetup = errors.New("auth: mud not set up")
+	ErrBanned   = errors.New("auth: site is banned")
 
-	bcryptCost int
-	mainTitle  []byte
-
-	// fakehash is a fake hashed password created with the current bcryptcost.
-	// It exists to allow us to fake out password hashing time when a username
-	// doesn't exist.
-	fakehash []byte
+	logger = logging.For("auth")
 )
 
 const (
 	retries = 3
 )
 
-// Init sets the bcryptcost for hashing passwords and sets up authentication.
-func Init(title string, cost int) {
-	mainTitle = []byte(title)
-	bcryptCost = cost
-	log.Printf("Using bcrypt cost %d", bcryptCost)
+// Config configures authentication and the connections of logged in users.
+type Config struct {
+	Title         string        // shown to users when they connect
+	BcryptCost    int           // cost of hashing passwords
+	MaxLineLength int           // the longest line users may type
+	OutputBuffer  int           // how much output can wait to be sent to a user
+	OutputStall   time.Duration // how long output can be blocked before the user is dropped
+
+	// Active is used to check whether a user is already logged in.
+	Active func(username string) bool
+
+	// Sent counts the bytes of output sent to logged in users.  It may be nil.
+	Sent *util.Counter
+}
+
+// Service logs users in to a world and sets up their connections.  Each world
+// has its own Service, so more than one world can run in a process.
+type Service struct {
+	cfg   Config
+	title []byte
+
+	// fakehash is a fake hashed password created with the configured bcrypt
+	// cost.  It exists to allow us to fake out password hashing time when a
+	// username doesn't exist.
+	fakehash []byte
+}
 
-	var err error
-	fakehash, err = bcrypt.GenerateFromPassword([]byte("password"), bcryptCost)
+// New returns a Service with the given config.
+func New(cfg Config) (*Service, error) {
+	logger.Info("using bcrypt", "cost", cfg.BcryptCost)
+	fakehash, err := bcrypt.GenerateFromPassword([]byte("password"), cfg.BcryptCost)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return &Service{
+		cfg:      cfg,
+		title:    []byte(cfg.Title),
+		fakehash: fakehash,
+	}, nil
 }
 
 // Login logs a user in from an incoming connection, creating a player
 // in the world if they successfully connect
-func Login(st *db.Store, rwc io.ReadWriteCloser, ip net.Addr) (*User, error) {
-	if err := showTitle(rwc); err != nil {
+func (s *Service) Login(st *db.Store, rwc io.ReadWriteCloser, ip net.Addr) (*User, error) {
+	if err := s.showTitle(rwc); err != nil {
 		return nil, err
 	}
-	var ws util.WriteScanner = struct {
-		io.Writer
-		*bufio.Scanner
-	}{
-		Writer:  rwc,
-		Scanner: bufio.NewScanner(rwc),
-	}
+	ws := s.newWriteScanner(rwc)
 	for i := 0; i < retries; i++ {
-		user, err := authenticate(st, ws, ip)
+		user, err := s.authenticate(st, ws, ip)
 		switch err {
 		case nil:
-			user.WriteScanner = ws
-			user.Closer = rwc
+			s.attach(user, rwc, ws, ip)
 			return user, nil
 		case ErrAuth:
-			log.Printf("Failed login from %s", ip)
+			logger.Info("failed login", logging.Addr(ip), logging.Event("login"))
 			_, err := io.WriteString(rwc, "Incorrect username or password, please try again\n")
 			if err != nil {
 				return nil, err
 			}
 			continue
 		case ErrDupe:
-			_, err = io.WriteString(rwc, "This account is already logged in.\n")
-			if err != nil {
-				return nil, err
-			}
+			continue
+		case ErrBanned:
+			// they may still log in to an existing account.
 			continue
 		case ErrNotSetup:
 			_ = rwc.Close()
 			return nil, ErrNotSetup
 		default:
-			log.Printf("Failed to log in user: %v", err)
+			logger.Error("failed to log in user", logging.Addr(ip), logging.Err(err), logging.Event("login"))
 			return nil, err
 		}
 	}
@@ -96,14 +110,105 @@ func Login(st *db.Store, rwc io.ReadWriteCloser, ip net.Addr) (*User, error) {
 	return nil, ErrAuth
 }
 
-func showTitle(w io.Writer) error {
-	_, err := w.Write(mainTitle)
+// LoginVerified logs in a user whose identity has already been verified by
+// the connection, such as with an SSH key.
+func (s *Service) LoginVerified(st *db.Store, rwc io.ReadWriteCloser, ip net.Addr, username string) (*User, error) {
+	if err := s.showTitle(rwc); err != nil {
+		return nil, err
+	}
+	ws := s.newWriteScanner(rwc)
+	if err := s.checkDupe(ws, username); err != nil {
+		if err == ErrDupe {
+			io.WriteString(rwc, "This account is already logged in.\n")
+		}
+		return nil, err
+	}
+	user, err := loadUser(st, username, ip)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("logged in without a password", logging.User(username), logging.Addr(ip), logging.Event("login"))
+	s.attach(user, rwc, ws, ip)
+	return user, nil
+}
+
+// Resume logs the user back in on a connection that was already logged in
+// before the MUD rebooted.  The user isn't asked for anything.
+func (s *Service) Resume(st *db.Store, rwc io.ReadWriteCloser, username string) (*User, error) {
+	u, err := st.FindUser(username)
+	if err != nil {
+		return nil, err
+	}
+	user := newUser(u)
+	var ip net.Addr
+	if c, ok := rwc.(net.Conn); ok {
+		ip = c.RemoteAddr()
+	}
+	s.attach(user, rwc, s.newWriteScanner(rwc), ip)
+	return user, nil
+}
+
+// checkDupe checks if the user is already logged in, and if so, asks whether to
+// take over the existing session.  It returns ErrDupe if they decline.
+func (s *Service) checkDupe(ws util.WriteScanner, username string) error {
+	if s.cfg.Active == nil || !s.cfg.Active(username) {
+		return nil
+	}
+	a, err := util.QueryOptions(ws, "\nThis account is already logged in.\n", 'c',
+		util.Opt{Key: 't', Text: "Take over the existing session"},
+		util.Opt{Key: 'c', Text: "Cancel"})
+	if err != nil {
+		return err
+	}
+	if a == 't' {
+		return nil
+	}
+	return ErrDupe
+}
+
+// CheckPassword verifies the user's password, for connections that handle
+// authentication themselves.
+func (s *Service) CheckPassword(st *db.Store, username, pass string, ip net.Addr) error {
+	_, err := s.checkPass(st, username, pass, ip)
+	return err
+}
+
+// writeScanner writes to and reads lines from a connection.
+type writeScanner struct {
+	io.Writer
+	*util.LineScanner
+}
+
+func (s *Service) newWriteScanner(rwc io.ReadWriter) *writeScanner {
+	return &writeScanner{
+		Writer:      rwc,
+		LineScanner: util.NewLineScanner(rwc, s.cfg.MaxLineLength),
+	}
+}
+
+// attach connects the user to the connection from the given address.  Once the
+// user is logged in, output is buffered and written on its own goroutine, so
+// that a slow connection can't hold up the rest of the MUD.
+func (s *Service) attach(user *User, rwc io.ReadWriteCloser, ws *writeScanner, ip net.Addr) {
+	out := util.NewAsyncWriter(rwc, s.cfg.OutputBuffer, s.cfg.OutputStall, s.cfg.Sent)
+	user.WriteScanner = &writeScanner{Writer: out, LineScanner: ws.LineScanner}
+	user.Closer = out
+	user.out = out
+	user.conn = rwc
+	user.addr = ip
+	if s, ok := rwc.(util.Sizer); ok {
+		user.size = s
+	}
+}
+
+func (s *Service) showTitle(w io.Writer) error {
+	_, err := w.Write(s.title)
 	return err
 }
 
 // authenticate queries the user for username and password, then authenticates
 // the credentials.
-func authenticate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error) {
+func (s *Service) authenticate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error) {
 	setup, err := st.IsSetup()
 	if err != nil {
 		return nil, fmt.Errorf("can't authenticate: %s", err)
@@ -121,7 +226,7 @@ func authenticate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error
 		if err := showIntro(ws); err != nil {
 			return nil, err
 		}
-		user, err := showCreate(st, ws, ip)
+		user, err := s.showCreate(st, ws, ip)
 		if err != nil {
 			return nil, err
 		}
@@ -138,13 +243,32 @@ func authenticate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error
 
 	switch a {
 	case 'c':
-		return showCreate(st, ws, ip)
+		ban, err := FindBan(st, ip)
+		if err != nil {
+			return nil, err
+		}
+		if ban != nil && ban.Level >= db.BanNewUsers {
+			logger.Info("refused new account from banned site", logging.Addr(ip), "ban", ban.Net, logging.Event("ban"))
+			_, err := io.WriteString(ws, "New accounts may not be created from your site.\n")
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrBanned
+		}
+		return s.showCreate(st, ws, ip)
 	case 'l':
 		u, p, err := queryCreds(ws)
 		if err != nil {
 			return nil, err
 		}
-		return checkPass(st, u, p, ip)
+		user, err := s.checkPass(st, u, p, ip)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkDupe(ws, u); err != nil {
+			return nil, err
+		}
+		return user, nil
 	default:
 		panic(fmt.Errorf("Should be impossible, got %v from login options", a))
 	}
@@ -172,7 +296,7 @@ machine where the MUD runs to start setup.`)
 }
 
 // showCreate leads the user through the process of creating a user.
-func showCreate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error) {
+func (s *Service) showCreate(st *db.Store, ws util.WriteScanner, ip net.Addr) (*User, error) {
 	_, err := io.WriteString(ws, `
 Please enter a username.  Note that this is only for use in logging into the MUD
 and will not be visible to non-admins.
@@ -187,7 +311,7 @@ and will not be visible to non-admins.
 		if err != nil {
 			return nil, err
 		}
-		user, err := createDBUser(st, u, pw, ip)
+		user, err := s.createDBUser(st, u, pw, ip)
 		if err == ErrExists {
 			_, err := io.WriteString(ws, "That username already exists, please choose another.\n")
 			if err != nil {
@@ -204,8 +328,8 @@ and will not be visible to non-admins.
 
 // createDBUser creates the user in the DB if it does not exist.  If it does exist,
 // createDBUser will return ErrExists.
-func createDBUser(st *db.Store, username, pw string, ip net.Addr) (*User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcryptCost)
+func (s *Service) createDBUser(st *db.Store, username, pw string, ip net.Addr) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), s.cfg.BcryptCost)
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +358,7 @@ func createDBUser(st *db.Store, username, pw string, ip net.Addr) (*User, error)
 		return nil, err
 	}
 	user.ID = util.ID(doc.ID)
-	log.Printf("created user %q (%d)", username, user.ID)
+	logger.Info("created user", logging.User(username), "id", user.ID, logging.Addr(ip), logging.Event("create"))
 	return user, nil
 }
 
@@ -281,19 +405,19 @@ func queryNewUser(st *db.Store, ws util.WriteScanner) (user, pwd string, err err
 }
 
 // checkPass verifies that the given user exists and that the password matches.
-func checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error) {
+func (s *Service) checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error) {
 	passb := []byte(pass)
 	c, err := st.FindCreds(username)
 	if _, ok := err.(db.ErrNotFound); ok {
 		// User does not exist. Fake out the time we would otherwise take to run
 		// the hash.  Ignore the error, we really only care about sucking up
 		// some CPU cycles here.
-		_ = bcrypt.CompareHashAndPassword(fakehash, passb)
+		_ = bcrypt.CompareHashAndPassword(s.fakehash, passb)
 		return nil, ErrAuth
 	}
 	start := time.Now()
 	err = bcrypt.CompareHashAndPassword(c.PwdHash, passb)
-	log.Printf("user password hashed in %v", time.Since(start))
+	logger.Debug("hashed user password", logging.User(username), "took", time.Since(start))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		return nil, ErrAuth
 	}
@@ -307,8 +431,8 @@ func checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error)
 	}
 
 	// Handle bcrypt cost change, rehash with new cost.
-	if cost != bcryptCost {
-		hash, err := bcrypt.GenerateFromPassword(passb, bcryptCost)
+	if cost != s.cfg.BcryptCost {
+		hash, err := bcrypt.GenerateFromPassword(passb, s.cfg.BcryptCost)
 		if err != nil {
 			return nil, err
 		}
@@ -317,7 +441,11 @@ func checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error)
 			return nil, err
 		}
 	}
-	// Login successful, update info.
+	return loadUser(st, username, ip)
+}
+
+// loadUser loads the user from the db and records the login.
+func loadUser(st *db.Store, username string, ip net.Addr) (*User, error) {
 	u, err := st.FindUser(username)
 	if err != nil {
 		return nil, err
@@ -327,6 +455,11 @@ func checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error)
 	if err := st.SaveUser(u); err != nil {
 		return nil, err
 	}
+	return newUser(u), nil
+}
+
+// newUser creates a User from the user's data in the db.
+func newUser(u *db.User) *User {
 	user := &User{
 		ID:       u.ID,
 		Username: u.Username,
@@ -336,5 +469,5 @@ func checkPass(st *db.Store, username, pass string, ip net.Addr) (*User, error)
 	if user.bits == nil {
 		user.bits = big.NewInt(0)
 	}
-	return user, nil
+	return user
 }
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/natefinch/claymud/db"
+)
+
+// FindBan returns the most restrictive ban that matches the address, or nil if
+// the address is not banned.
+func FindBan(st *db.Store, addr net.Addr) (*db.Ban, error) {
+	ip := addrIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("can't get IP address from %q", addr)
+	}
+	bans, err := st.Bans()
+	if err != nil {
+		return nil, err
+	}
+	var found *db.Ban
+	for i := range bans {
+		_, ipnet, err := net.ParseCIDR(bans[i].Net)
+		if err != nil {
+			// should be impossible, since we check when creating the ban.
+			continue
+		}
+		if !ipnet.Contains(ip) {
+			continue
+		}
+		if found == nil || bans[i].Level > found.Level {
+			found = &bans[i]
+		}
+	}
+	return found, nil
+}
+
+// Ban bans the given IP address or CIDR network.
+func Ban(st *db.Store, site string, level db.BanLevel, reason, by string) (db.Ban, error) {
+	network, err := ParseSite(site)
+	if err != nil {
+		return db.Ban{}, err
+	}
+	ban := db.Ban{
+		Net:     network,
+		Level:   level,
+		Reason:  reason,
+		By:      by,
+		Created: time.Now(),
+	}
+	return ban, st.SaveBan(ban)
+}
+
+// Unban removes the ban on the given IP address or CIDR network.
+func Unban(st *db.Store, site string) error {
+	network, err := ParseSite(site)
+	if err != nil {
+		return err
+	}
+	return st.RemoveBan(network)
+}
+
+// ParseSite converts an IP address or CIDR network into the normalized CIDR
+// form used to store bans.  A single IP address becomes a network containing
+// only that address.
+func ParseSite(site string) (string, error) {
+	if !strings.Contains(site, "/") {
+		ip := net.ParseIP(site)
+		if ip == nil {
+			return "", fmt.Errorf("%q is not an IP address or CIDR network", site)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, ipnet, err := net.ParseCIDR(site)
+	if err != nil {
+		return "", fmt.Errorf("%q is not an IP address or CIDR network", site)
+	}
+	return ipnet.String(), nil
+}
+
+// addrIP returns the IP address of the network address.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
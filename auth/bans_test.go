@@ -0,0 +1,30 @@
+package auth
+
+import "testing"
+
+func TestParseSite(t *testing.T) {
+	tests := []struct {
+		site     string
+		expected string
+	}{
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.3/16", "10.1.0.0/16"},
+		{"::1", "::1/128"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+	}
+	for _, test := range tests {
+		got, err := ParseSite(test.site)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", test.site, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("expected %q to parse as %q, got %q", test.site, test.expected, got)
+		}
+	}
+	for _, bad := range []string{"", "example.com", "10.1.2.3/33"} {
+		if _, err := ParseSite(bad); err == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/logging"
+)
+
+// CheckKey reports whether the SSH public key belongs to the user.
+func CheckKey(st *db.Store, username string, key ssh.PublicKey) (bool, error) {
+	c, err := st.FindCreds(username)
+	if _, ok := err.(db.ErrNotFound); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	want := key.Marshal()
+	for _, line := range c.PublicKeys {
+		k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			logger.Warn("ignoring bad public key", logging.User(username), logging.Err(err))
+			continue
+		}
+		if bytes.Equal(k.Marshal(), want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Keys returns the fingerprints and comments of the user's SSH public keys.
+func Keys(st *db.Store, username string) ([]string, error) {
+	c, err := st.FindCreds(username)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(c.PublicKeys))
+	for _, line := range c.PublicKeys {
+		k, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			keys = append(keys, "<invalid key>")
+			continue
+		}
+		keys = append(keys, strings.TrimSpace(ssh.FingerprintSHA256(k)+" "+comment))
+	}
+	return keys, nil
+}
+
+// AddKey adds an authorized_keys formatted SSH public key to the user's
+// credentials, so they can log in with SSH without a password.
+func AddKey(st *db.Store, username, line string) error {
+	k, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return fmt.Errorf("that is not a valid public key: %v", err)
+	}
+	c, err := st.FindCreds(username)
+	if err != nil {
+		return err
+	}
+	// store it normalized, without any options that might have been included.
+	normal := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(k)))
+	if comment != "" {
+		normal += " " + comment
+	}
+	c.PublicKeys = append(c.PublicKeys, normal)
+	return st.SaveCreds(c)
+}
+
+// RemoveKey removes the user's SSH public key at the given (zero-based) index
+// in the list returned by Keys.
+func RemoveKey(st *db.Store, username string, index int) error {
+	c, err := st.FindCreds(username)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(c.PublicKeys) {
+		return fmt.Errorf("there is no key number %d", index+1)
+	}
+	c.PublicKeys = append(c.PublicKeys[:index], c.PublicKeys[index+1:]...)
+	return st.SaveCreds(c)
+}
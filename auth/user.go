@@ -3,6 +3,8 @@ package auth
 import (
 	"io"
 	"math/big"
+	"net"
+	"time"
 
 	"github.com/natefinch/claymud/util"
 )
@@ -25,10 +27,43 @@ type User struct {
 	Username string
 	Players  []string
 	bits     *big.Int
+	size     util.Sizer
+	conn     io.ReadWriteCloser
+	addr     net.Addr
+	out      *util.AsyncWriter
 	io.Closer
 	util.WriteScanner
 }
 
+// Width returns the width of the user's terminal, or 0 if it's not known.
+func (u *User) Width() int {
+	if u.size == nil {
+		return 0
+	}
+	w, _ := u.size.Size()
+	return w
+}
+
+// Conn returns the connection the user is logged in on.
+func (u *User) Conn() io.ReadWriteCloser {
+	return u.conn
+}
+
+// Addr returns the address the user connected from, or nil if it isn't known.
+func (u *User) Addr() net.Addr {
+	return u.addr
+}
+
+// OutputStats returns the state of the user's output buffer.
+func (u *User) OutputStats() util.WriterStats {
+	return u.out.Stats()
+}
+
+// Flush waits up to the timeout for the output queued for the user to be sent.
+func (u *User) Flush(timeout time.Duration) error {
+	return u.out.Flush(timeout)
+}
+
 // Flag reports if the given flag has been set to true for the user.
 func (u *User) Flag(f UFlag) bool {
 	return u.bits.Bit(int(f)) == 1
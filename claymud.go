@@ -6,11 +6,18 @@ import (
 	"log"
 	"os"
 
+	"github.com/natefinch/claymud/loadtest"
 	"github.com/natefinch/claymud/server"
 )
 
 func main() {
-	if err := server.Main(); err != nil {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		err = loadtest.Main(os.Args[2:])
+	} else {
+		err = server.Main()
+	}
+	if err != nil {
 		log.Print(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var bansBucket = []byte("bans")
+
+// BanLevel determines what people connecting from a banned site are allowed to
+// do.
+type BanLevel int
+
+// All the ban levels, from least to most restrictive.
+//
+// DO NOT REARRANGE OR COMMENT OUT VALUES.  These are stored in the db.
+const (
+	BanNotice   BanLevel = iota // allowed to connect, but shown a notice
+	BanNewUsers                 // not allowed to create new accounts
+	BanAll                      // not allowed to connect at all
+)
+
+var banLevelNames = []string{"notice", "new", "all"}
+
+// String returns the name of the ban level.
+func (l BanLevel) String() string {
+	if l < 0 || int(l) >= len(banLevelNames) {
+		return fmt.Sprintf("BanLevel(%d)", int(l))
+	}
+	return banLevelNames[l]
+}
+
+// ParseBanLevel converts a name from BanLevel.String back into a BanLevel.
+func ParseBanLevel(s string) (BanLevel, error) {
+	for i, name := range banLevelNames {
+		if strings.EqualFold(s, name) {
+			return BanLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown ban level %q, expected one of %s", s, strings.Join(banLevelNames, ", "))
+}
+
+// Ban is the structure that is stored in the database for a banned site.
+type Ban struct {
+	Net     string // the banned IP network in CIDR notation
+	Level   BanLevel
+	Reason  string
+	By      string // username of the admin that created the ban
+	Created time.Time
+}
+
+// Bans returns all the bans in the db.
+func (st *Store) Bans() ([]Ban, error) {
+	var bans []Ban
+	err := st.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bansBucket)
+		if b == nil {
+			return ErrNoBucket("bans")
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var ban Ban
+			if _, err := get(b, k, &ban); err != nil {
+				return err
+			}
+			bans = append(bans, ban)
+			return nil
+		})
+	})
+	return bans, err
+}
+
+// SaveBan saves the ban to the db, replacing any existing ban for the same
+// network.
+func (st *Store) SaveBan(ban Ban) error {
+	return st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bansBucket)
+		if b == nil {
+			return ErrNoBucket("bans")
+		}
+		return put(b, []byte(ban.Net), ban)
+	})
+}
+
+// RemoveBan removes the ban for the given network.
+func (st *Store) RemoveBan(network string) error {
+	return st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bansBucket)
+		if b == nil {
+			return ErrNoBucket("bans")
+		}
+		if b.Get([]byte(network)) == nil {
+			return ErrNotFound("ban")
+		}
+		return b.Delete([]byte(network))
+	})
+}
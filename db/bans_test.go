@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveRemoveBan(t *testing.T) {
+	st, cleanup := tmpStore(t)
+	defer cleanup()
+	ban := Ban{
+		Net:     "10.0.0.0/8",
+		Level:   BanNewUsers,
+		Reason:  "spammers",
+		By:      "admin",
+		Created: time.Now().Round(0),
+	}
+	if err := st.SaveBan(ban); err != nil {
+		t.Fatal(err)
+	}
+	bans, err := st.Bans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 {
+		t.Fatalf("expected 1 ban, got %#v", bans)
+	}
+	if bans[0].Net != ban.Net || bans[0].Level != ban.Level || bans[0].Reason != ban.Reason {
+		t.Fatalf("expected %#v, got %#v", ban, bans[0])
+	}
+
+	if err := st.RemoveBan(ban.Net); err != nil {
+		t.Fatal(err)
+	}
+	bans, err = st.Bans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 0 {
+		t.Fatalf("expected no bans, got %#v", bans)
+	}
+	err = st.RemoveBan(ban.Net)
+	if _, ok := err.(ErrNotFound); !ok {
+		t.Fatalf("expected to get ErrNotFound but got %#v", err)
+	}
+}
+
+func TestParseBanLevel(t *testing.T) {
+	for _, l := range []BanLevel{BanNotice, BanNewUsers, BanAll} {
+		got, err := ParseBanLevel(l.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != l {
+			t.Errorf("expected %v, got %v", l, got)
+		}
+	}
+	if _, err := ParseBanLevel("bogus"); err == nil {
+		t.Fatal("expected error parsing bogus ban level")
+	}
+}
@@ -8,6 +8,9 @@ var credsBucket = []byte("credentials")
 type Credentials struct {
 	Username string
 	PwdHash  []byte
+
+	// PublicKeys are the user's SSH public keys in authorized_keys format.
+	PublicKeys []string
 }
 
 // FindCreds returns the user's credentials.
@@ -29,6 +29,10 @@ func Init(dir string) (*Store, error) {
 			return err
 		}
 		_, err = tx.CreateBucketIfNotExists(credsBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(bansBucket)
 		return err
 	})
 	if err != nil {
@@ -37,6 +41,11 @@ func Init(dir string) (*Store, error) {
 	return &Store{db: db}, nil
 }
 
+// Close closes the database.
+func (st *Store) Close() error {
+	return st.db.Close()
+}
+
 // IsSetup returns true if the database has been setup.
 func (st *Store) IsSetup() (bool, error) {
 	var setup bool
@@ -18,6 +18,9 @@ type Player struct {
 	ID          util.ID
 	Gender      game.Gender
 	Flags       *big.Int
+	Attributes  map[string]int // keyed by the attribute's name
+	Race        string
+	Class       string
 }
 
 // FindPlayer returns the player with the given name. This is a
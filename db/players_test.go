@@ -71,6 +71,9 @@ func fakePlayer(t *testing.T) *Player {
 			Xim:   "fooim",
 			Xis:   "foois",
 		},
-		Flags: big.NewInt(17),
+		Flags:      big.NewInt(17),
+		Attributes: map[string]int{"STR": 12, "DEX": 9},
+		Race:       "Elf",
+		Class:      "Mage",
 	}
 }
@@ -0,0 +1,117 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attribute is a score every character has, like strength or dexterity.
+type Attribute struct {
+	Name string // the short name, like STR
+	Long string // the full name, like Strength.  Defaults to Name.
+	Min  int
+	Max  int
+
+	// Start is the value new characters get if there's no Roll, and the value
+	// existing characters get when the attribute is added to the game.  It
+	// defaults to Min, and Validate always sets it.
+	Start *int
+
+	// Roll is the dice rolled for new characters, like "3d6".  Rolls outside
+	// of Min and Max are moved into range.
+	Roll string
+
+	dice *Dice
+}
+
+// Clamp returns the value moved into the attribute's range.
+func (a Attribute) Clamp(v int) int {
+	switch {
+	case v < a.Min:
+		return a.Min
+	case v > a.Max:
+		return a.Max
+	default:
+		return v
+	}
+}
+
+// Attributes are all the attributes in the game, in the order they're shown to
+// players.
+type Attributes []Attribute
+
+// Validate checks that the attributes make sense, and fills in their defaults.
+func (attrs Attributes) Validate() error {
+	seen := map[string]bool{}
+	for i := range attrs {
+		a := &attrs[i]
+		switch {
+		case a.Name == "" || strings.ContainsAny(a.Name, " \t"):
+			return fmt.Errorf("attribute names must be a single word, but got %q", a.Name)
+		case seen[strings.ToLower(a.Name)]:
+			return fmt.Errorf("attribute %q is defined more than once", a.Name)
+		case a.Min > a.Max:
+			return fmt.Errorf("attribute %q has a min of %d, which is more than its max of %d", a.Name, a.Min, a.Max)
+		}
+		seen[strings.ToLower(a.Name)] = true
+		if a.Long == "" {
+			a.Long = a.Name
+		}
+		if a.Start == nil {
+			start := a.Min
+			a.Start = &start
+		}
+		if *a.Start != a.Clamp(*a.Start) {
+			return fmt.Errorf("attribute %q starts at %d, which is outside of %d to %d", a.Name, *a.Start, a.Min, a.Max)
+		}
+		if a.Roll != "" {
+			d, err := MakeDice(a.Roll)
+			if err != nil {
+				return fmt.Errorf("attribute %q: %v", a.Name, err)
+			}
+			if d.Count < 1 || d.Size < 1 {
+				return fmt.Errorf("attribute %q must roll at least one die with at least one side, but got %q", a.Name, a.Roll)
+			}
+			a.dice = &d
+		}
+	}
+	return nil
+}
+
+// Find returns the attribute with the given name, ignoring case.
+func (attrs Attributes) Find(name string) (Attribute, bool) {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Rolled reports whether any of the attributes are rolled for new characters.
+func (attrs Attributes) Rolled() bool {
+	for _, a := range attrs {
+		if a.dice != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Roll returns the starting attributes for a new character, keyed by name, with
+// the modifiers added, such as those for the character's race.  The attributes
+// must have been validated.
+func (attrs Attributes) Roll(r *Rand, mods ...map[string]int) map[string]int {
+	vals := make(map[string]int, len(attrs))
+	for _, a := range attrs {
+		v := *a.Start
+		if a.dice != nil {
+			v = a.dice.Roll(r)
+		}
+		for _, m := range mods {
+			v += m[a.Name]
+		}
+		vals[a.Name] = a.Clamp(v)
+	}
+	return vals
+}
@@ -0,0 +1,99 @@
+package game
+
+import "testing"
+
+func TestAttributesValidate(t *testing.T) {
+	attrs := Attributes{
+		{Name: "STR", Long: "Strength", Min: 3, Max: 18, Roll: "3d6"},
+		{Name: "LUCK", Min: 1, Max: 10, Start: intp(5)},
+	}
+	if err := attrs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if *attrs[0].Start != 3 {
+		t.Errorf("expected STR to start at its min of 3, got %d", *attrs[0].Start)
+	}
+	if attrs[1].Long != "LUCK" {
+		t.Errorf("expected LUCK's long name to default to its name, got %q", attrs[1].Long)
+	}
+	if !attrs.Rolled() {
+		t.Error("expected the attributes to be rolled")
+	}
+	if a, ok := attrs.Find("str"); !ok || a.Long != "Strength" {
+		t.Errorf("expected to find STR by lowercase name, got %+v", a)
+	}
+
+	for _, bad := range []Attributes{
+		{{Name: "two words", Max: 1}},
+		{{Name: "STR", Max: 1}, {Name: "str", Max: 1}},
+		{{Name: "STR", Min: 5, Max: 1}},
+		{{Name: "STR", Min: 1, Max: 5, Start: intp(6)}},
+		{{Name: "STR", Min: 1, Max: 5, Start: intp(0)}},
+		{{Name: "STR", Max: 5, Roll: "lots"}},
+		{{Name: "STR", Max: 5, Roll: "0d6"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestAttributesRollPenalty(t *testing.T) {
+	attrs := Attributes{{Name: "LUCK", Min: -10, Max: 10, Roll: "1d1-3"}}
+	if err := attrs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if vals := attrs.Roll(NewRand(1)); vals["LUCK"] != -2 {
+		t.Errorf("expected 1d1-3 to roll -2, got %d", vals["LUCK"])
+	}
+}
+
+func TestAttributesStartAtZero(t *testing.T) {
+	attrs := Attributes{
+		{Name: "KARMA", Min: -10, Max: 10, Start: intp(0)},
+		{Name: "HONOR", Min: -10, Max: 10},
+	}
+	if err := attrs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if *attrs[0].Start != 0 {
+		t.Errorf("expected KARMA to start at its explicit 0, got %d", *attrs[0].Start)
+	}
+	if *attrs[1].Start != -10 {
+		t.Errorf("expected HONOR to start at its min of -10, got %d", *attrs[1].Start)
+	}
+	if vals := attrs.Roll(NewRand(1)); vals["KARMA"] != 0 {
+		t.Errorf("expected a new character's KARMA to be 0, got %d", vals["KARMA"])
+	}
+}
+
+func TestAttributesRoll(t *testing.T) {
+	attrs := Attributes{
+		{Name: "STR", Min: 3, Max: 10, Roll: "3d6"},
+		{Name: "LUCK", Min: 1, Max: 10, Start: intp(5)},
+	}
+	if err := attrs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRand(1)
+	for i := 0; i < 100; i++ {
+		vals := attrs.Roll(r)
+		if vals["STR"] < 3 || vals["STR"] > 10 {
+			t.Fatalf("STR of %d is outside of 3 to 10", vals["STR"])
+		}
+		if vals["LUCK"] != 5 {
+			t.Fatalf("expected LUCK to be its start value of 5, got %d", vals["LUCK"])
+		}
+	}
+	vals := attrs.Roll(r, map[string]int{"LUCK": 2}, map[string]int{"LUCK": 1, "STR": -20})
+	if vals["LUCK"] != 8 {
+		t.Errorf("expected modifiers to add to LUCK, got %d", vals["LUCK"])
+	}
+	if vals["STR"] != 3 {
+		t.Errorf("expected modifiers to stay within STR's range, got %d", vals["STR"])
+	}
+}
+
+func intp(i int) *int {
+	return &i
+}
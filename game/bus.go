@@ -0,0 +1,102 @@
+package game
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Bus delivers events to whoever has subscribed to them.  Events are plain
+// values, and subscribers are chosen by the event's type, so a subscriber to
+// one kind of event never sees the others.
+//
+// Each subscriber says which worker it wants its events on, and its handler is
+// posted to that worker, so it can use whatever the worker owns just like any
+// other event.  Events published from one goroutine arrive in the order they
+// were published.  Subscribers with no worker are called right away on the
+// publisher's goroutine, so they must be quick and safe to call from any
+// goroutine, like a counter or a logger.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[reflect.Type][]*Subscription
+}
+
+// NewBus returns a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: map[reflect.Type][]*Subscription{}}
+}
+
+// Subscription is a subscriber's registration on a bus.
+type Subscription struct {
+	bus       *Bus
+	typ       reflect.Type
+	w         *Worker
+	fn        func(interface{})
+	cancelled int32 // accessed atomically
+}
+
+// Cancel stops the subscriber from getting any more events, including ones
+// that were published but haven't reached its worker yet.  It is safe to call
+// more than once, and from any goroutine.
+func (s *Subscription) Cancel() {
+	if !atomic.CompareAndSwapInt32(&s.cancelled, 0, 1) {
+		return
+	}
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := b.subs[s.typ]
+	// copy rather than edit in place, since publishers may be looping over
+	// the old slice.
+	kept := make([]*Subscription, 0, len(subs))
+	for _, sub := range subs {
+		if sub != s {
+			kept = append(kept, sub)
+		}
+	}
+	b.subs[s.typ] = kept
+}
+
+// deliver sends the event to the subscriber.
+func (s *Subscription) deliver(e interface{}) {
+	if s.w == nil {
+		s.fn(e)
+		return
+	}
+	s.w.Post(func() {
+		if atomic.LoadInt32(&s.cancelled) == 0 {
+			s.fn(e)
+		}
+	})
+}
+
+// Subscribe calls fn with every event of type E published on the bus, on the
+// given worker, or on the publisher's goroutine if the worker is nil.
+func Subscribe[E any](b *Bus, w *Worker, fn func(E)) *Subscription {
+	s := &Subscription{
+		bus: b,
+		typ: reflect.TypeOf((*E)(nil)).Elem(),
+		w:   w,
+		fn:  func(e interface{}) { fn(e.(E)) },
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// copy rather than append in place, since publishers may be looping over
+	// the old slice.
+	old := b.subs[s.typ]
+	subs := make([]*Subscription, len(old), len(old)+1)
+	copy(subs, old)
+	b.subs[s.typ] = append(subs, s)
+	return s
+}
+
+// Publish sends the event to everyone subscribed to events of its type.  It
+// never blocks, so it is safe to call from inside any worker's events.
+func Publish[E any](b *Bus, e E) {
+	b.mu.RLock()
+	subs := b.subs[reflect.TypeOf((*E)(nil)).Elem()]
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.deliver(e)
+	}
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type said struct{ msg string }
+type moved struct{ to int }
+
+func TestBus(t *testing.T) {
+	clock := NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := NewManualWorker("test", &sync.Mutex{}, clock)
+	b := NewBus()
+
+	var onWorker, right, moves []string
+	Subscribe(b, w, func(e said) { onWorker = append(onWorker, e.msg) })
+	Subscribe(b, nil, func(e said) { right = append(right, e.msg) })
+	Subscribe(b, nil, func(e moved) { moves = append(moves, "moved") })
+	cancelled := Subscribe(b, w, func(e said) { t.Errorf("cancelled subscriber got %q", e.msg) })
+
+	Publish(b, said{"hi"})
+	Publish(b, said{"bye"})
+	cancelled.Cancel()
+
+	if expected := []string{"hi", "bye"}; !reflect.DeepEqual(right, expected) {
+		t.Errorf("expected subscribers without a worker to get %v right away, got %v", expected, right)
+	}
+	if len(onWorker) != 0 {
+		t.Errorf("expected events to wait for the worker, got %v", onWorker)
+	}
+	w.Step()
+	if expected := []string{"hi", "bye"}; !reflect.DeepEqual(onWorker, expected) {
+		t.Errorf("expected %v on the worker, got %v", expected, onWorker)
+	}
+	if len(moves) != 0 {
+		t.Errorf("subscriber got events of the wrong type: %v", moves)
+	}
+}
+
+func TestPublishFromWorker(t *testing.T) {
+	shutdown := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer close(shutdown)
+	w := SpawnWorker("test", &sync.Mutex{}, SystemClock, shutdown, wg)
+	b := NewBus()
+
+	got := make(chan string, 1)
+	Subscribe(b, w, func(e said) { got <- e.msg })
+	// publishing to the worker the event is running on must not deadlock.
+	w.Handle(func() { Publish(b, said{"echo"}) })
+	select {
+	case msg := <-got:
+		if msg != "echo" {
+			t.Errorf("expected echo, got %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
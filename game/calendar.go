@@ -0,0 +1,163 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Calendar describes how time passes in the game world.  Game time is counted
+// in hours since the world began, and the calendar turns that count into
+// days, months, years, and seasons.
+type Calendar struct {
+	HourLength   time.Duration // how much real time a game hour lasts
+	HoursPerDay  int
+	DaysPerMonth int
+	Sunrise      int      // the hour the sun comes up
+	Sunset       int      // the hour the sun goes down
+	Months       []string // the names of the months, in order
+	Seasons      []Season
+}
+
+// Season is a part of the year with its own weather.
+type Season struct {
+	Name       string
+	FirstMonth int // the number of the season's first month, starting at 1
+
+	// Wetness makes bad weather more likely in this season, or less likely if
+	// it's negative.  It adds to the wetness of each zone.
+	Wetness int
+}
+
+// DefaultCalendar is a calendar with a 24 hour day that passes a game hour
+// every real minute.
+var DefaultCalendar = Calendar{
+	HourLength:   time.Minute,
+	HoursPerDay:  24,
+	DaysPerMonth: 30,
+	Sunrise:      6,
+	Sunset:       20,
+	Months: []string{
+		"Deepwinter", "the Claw of Winter", "the Melting", "Rain",
+		"Flowers", "the Sun", "Highsun", "the Harvest",
+		"the Fading", "Leaffall", "Rotting", "the Drawing Down",
+	},
+	Seasons: []Season{
+		{Name: "Winter", FirstMonth: 12, Wetness: 1},
+		{Name: "Spring", FirstMonth: 3, Wetness: 1},
+		{Name: "Summer", FirstMonth: 6, Wetness: -1},
+		{Name: "Autumn", FirstMonth: 9},
+	},
+}
+
+// Validate checks that the calendar makes sense, and sorts a copy of its
+// seasons by their first month.
+func (c *Calendar) Validate() error {
+	switch {
+	case c.HourLength < TickLen:
+		return fmt.Errorf("calendar hour length must be at least %v, but got %v", TickLen, c.HourLength)
+	case c.HoursPerDay < 1:
+		return fmt.Errorf("calendar must have at least one hour per day, but got %d", c.HoursPerDay)
+	case c.DaysPerMonth < 1:
+		return fmt.Errorf("calendar must have at least one day per month, but got %d", c.DaysPerMonth)
+	case len(c.Months) == 0:
+		return fmt.Errorf("calendar must have at least one month")
+	case c.Sunrise < 0 || c.Sunrise >= c.HoursPerDay:
+		return fmt.Errorf("calendar sunrise must be an hour of the day, but got %d", c.Sunrise)
+	case c.Sunset < 0 || c.Sunset >= c.HoursPerDay:
+		return fmt.Errorf("calendar sunset must be an hour of the day, but got %d", c.Sunset)
+	case c.Sunrise >= c.Sunset:
+		return fmt.Errorf("calendar sunrise (%d) must come before sunset (%d)", c.Sunrise, c.Sunset)
+	}
+	for _, s := range c.Seasons {
+		if s.FirstMonth < 1 || s.FirstMonth > len(c.Months) {
+			return fmt.Errorf("season %q starts in month %d, but there are only %d months", s.Name, s.FirstMonth, len(c.Months))
+		}
+	}
+	// copy before sorting, so calendars copied from each other don't change
+	// under each other.
+	seasons := append([]Season(nil), c.Seasons...)
+	sort.SliceStable(seasons, func(i, j int) bool { return seasons[i].FirstMonth < seasons[j].FirstMonth })
+	c.Seasons = seasons
+	return nil
+}
+
+// Time returns the game time the given number of hours after the world began.
+func (c *Calendar) Time(hours int64) Time {
+	days := hours / int64(c.HoursPerDay)
+	months := days / int64(c.DaysPerMonth)
+	return Time{
+		Hour:  int(hours % int64(c.HoursPerDay)),
+		Day:   int(days%int64(c.DaysPerMonth)) + 1,
+		Month: int(months%int64(len(c.Months))) + 1,
+		Year:  int(months/int64(len(c.Months))) + 1,
+		cal:   c,
+	}
+}
+
+// Time is a moment in game time.  Days, months, and years start at 1, hours
+// start at 0.
+type Time struct {
+	Hour, Day, Month, Year int
+
+	cal *Calendar
+}
+
+// MonthName returns the name of the month.
+func (t Time) MonthName() string {
+	return t.cal.Months[t.Month-1]
+}
+
+// Season returns the season the time falls in.  A season lasts until the next
+// one starts, so the last season of the year carries on into the start of the
+// next year.  It returns the zero Season if the calendar has no seasons.
+func (t Time) Season() Season {
+	seasons := t.cal.Seasons
+	if len(seasons) == 0 {
+		return Season{}
+	}
+	s := seasons[len(seasons)-1]
+	for _, next := range seasons {
+		if next.FirstMonth > t.Month {
+			break
+		}
+		s = next
+	}
+	return s
+}
+
+// Daytime reports whether the sun is up.
+func (t Time) Daytime() bool {
+	return t.Hour >= t.cal.Sunrise && t.Hour < t.cal.Sunset
+}
+
+// Sunrise reports whether the sun comes up this hour.
+func (t Time) Sunrise() bool {
+	return t.Hour == t.cal.Sunrise
+}
+
+// Sunset reports whether the sun goes down this hour.
+func (t Time) Sunset() bool {
+	return t.Hour == t.cal.Sunset
+}
+
+// String returns the time as players would say it, like "4 o'clock on the 3rd
+// day of the Harvest, year 12".
+func (t Time) String() string {
+	return fmt.Sprintf("%d o'clock on the %s day of %s, year %d", t.Hour, ordinal(t.Day), t.MonthName(), t.Year)
+}
+
+// ordinal returns the number with its English suffix, like 1st or 12th.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
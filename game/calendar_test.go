@@ -0,0 +1,101 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func testCalendar(t *testing.T) *Calendar {
+	c := &Calendar{
+		HourLength:   time.Minute,
+		HoursPerDay:  10,
+		DaysPerMonth: 3,
+		Sunrise:      3,
+		Sunset:       8,
+		Months:       []string{"Frost", "Thaw", "Bloom", "Harvest"},
+		Seasons: []Season{
+			{Name: "Summer", FirstMonth: 3},
+			{Name: "Winter", FirstMonth: 4},
+			{Name: "Spring", FirstMonth: 2},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCalendarTime(t *testing.T) {
+	c := testCalendar(t)
+	for _, test := range []struct {
+		hours    int64
+		expected string
+		season   string
+		daytime  bool
+	}{
+		{0, "0 o'clock on the 1st day of Frost, year 1", "Winter", false},
+		{3, "3 o'clock on the 1st day of Frost, year 1", "Winter", true},
+		{17, "7 o'clock on the 2nd day of Frost, year 1", "Winter", true},
+		{38, "8 o'clock on the 1st day of Thaw, year 1", "Spring", false},
+		{60, "0 o'clock on the 1st day of Bloom, year 1", "Summer", false},
+		{129, "9 o'clock on the 1st day of Frost, year 2", "Winter", false},
+	} {
+		tm := c.Time(test.hours)
+		if s := tm.String(); s != test.expected {
+			t.Errorf("expected hour %d to be %q, got %q", test.hours, test.expected, s)
+		}
+		if s := tm.Season().Name; s != test.season {
+			t.Errorf("expected hour %d to be in %s, got %s", test.hours, test.season, s)
+		}
+		if tm.Daytime() != test.daytime {
+			t.Errorf("expected daytime at hour %d to be %v", test.hours, test.daytime)
+		}
+	}
+	if !c.Time(13).Sunrise() || !c.Time(18).Sunset() || c.Time(14).Sunrise() {
+		t.Error("sunrise or sunset at the wrong hour")
+	}
+}
+
+func TestCalendarValidate(t *testing.T) {
+	c := DefaultCalendar
+	c.Sunrise, c.Sunset = 20, 6
+	if err := c.Validate(); err == nil {
+		t.Error("expected an error for sunset before sunrise")
+	}
+	c = DefaultCalendar
+	c.Seasons = []Season{{Name: "Never", FirstMonth: 13}}
+	if err := c.Validate(); err == nil {
+		t.Error("expected an error for a season in a month that doesn't exist")
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, expected := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 21: "21st", 113: "113th"} {
+		if s := ordinal(n); s != expected {
+			t.Errorf("expected %d to be %q, got %q", n, expected, s)
+		}
+	}
+}
+
+func TestWeatherNext(t *testing.T) {
+	r := NewRand(1)
+	counts := map[int]map[Weather]int{}
+	for _, wetness := range []int{-3, 3} {
+		counts[wetness] = map[Weather]int{}
+		w := WeatherClear
+		for i := 0; i < 10000; i++ {
+			next := w.Next(r, wetness)
+			if d := next - w; d > 1 || d < -1 {
+				t.Fatalf("weather jumped from %v to %v", w, next)
+			}
+			w = next
+			counts[wetness][w]++
+		}
+	}
+	if counts[-3][WeatherClear] <= counts[3][WeatherClear] {
+		t.Errorf("expected dry weather to be clear more often than wet weather, got %v and %v", counts[-3], counts[3])
+	}
+	if counts[3][WeatherStorm] <= counts[-3][WeatherStorm] {
+		t.Errorf("expected wet weather to storm more often than dry weather, got %v and %v", counts[3], counts[-3])
+	}
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it to pass.  Workers and the world get the
+// time from a Clock rather than the time package, so that tests and simulations
+// can control how time passes.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the real wall clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// ManualClock is a Clock whose time only moves when Advance is called.  It is
+// safe for concurrent use.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	changed chan struct{} // closed when the time changes
+}
+
+// NewManualClock returns a ManualClock set to the given time.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock has been advanced by at least d.
+func (c *ManualClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	until := c.now.Add(d)
+	for c.now.Before(until) {
+		changed := c.changed
+		c.mu.Unlock()
+		<-changed
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+}
+
+// Advance moves the clock forward by d, waking anyone sleeping past the new
+// time.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
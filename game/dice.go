@@ -2,7 +2,6 @@ package game
 
 import (
 	"fmt"
-	"math/rand"
 	"strconv"
 	"strings"
 )
@@ -12,11 +11,11 @@ type Dice struct {
 	Count, Size, Modifier int
 }
 
-// Roll rolls the dice and returns the result.
-func (d Dice) Roll() int {
+// Roll rolls the dice using r and returns the result.
+func (d Dice) Roll(r *Rand) int {
 	result := 0
 	for i := 0; i < d.Count; i++ {
-		result += rand.Intn(d.Size) + 1
+		result += r.Intn(d.Size) + 1
 	}
 	result += d.Modifier
 	return result
@@ -45,7 +44,8 @@ func MakeDice(s string) (Dice, error) {
 	if err != nil {
 		return Dice{}, fmt.Errorf("size of dice is not a number: %v", s)
 	}
-	s = vals[1][sep+1:]
+	// keep the sign, so 3d6-2 is a penalty.
+	s = vals[1][sep:]
 	mod, err := strconv.Atoi(s)
 	if err != nil {
 		return Dice{}, fmt.Errorf("modifier on dice is not a number: %v", s)
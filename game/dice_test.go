@@ -0,0 +1,46 @@
+package game
+
+import "testing"
+
+func TestMakeDice(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Dice
+	}{
+		{s: "3d6", expected: Dice{Count: 3, Size: 6}},
+		{s: "3d6+2", expected: Dice{Count: 3, Size: 6, Modifier: 2}},
+		{s: "3d6-2", expected: Dice{Count: 3, Size: 6, Modifier: -2}},
+	}
+	for _, test := range tests {
+		d, err := MakeDice(test.s)
+		if err != nil {
+			t.Errorf("MakeDice(%q): unexpected error: %v", test.s, err)
+			continue
+		}
+		if d != test.expected {
+			t.Errorf("MakeDice(%q): expected %+v, got %+v", test.s, test.expected, d)
+		}
+	}
+	for _, bad := range []string{"", "3", "d6", "3d", "3dx", "3d6+", "3d6+-2", "3d6+x"} {
+		if _, err := MakeDice(bad); err == nil {
+			t.Errorf("MakeDice(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestDiceRollIsSeeded(t *testing.T) {
+	d, err := MakeDice("3d6+2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := NewRand(42), NewRand(42)
+	for i := 0; i < 20; i++ {
+		x, y := d.Roll(a), d.Roll(b)
+		if x != y {
+			t.Fatalf("roll %d: rolls with the same seed differ: %d != %d", i, x, y)
+		}
+		if x < 5 || x > 20 {
+			t.Fatalf("roll %d: %d is out of range for 3d6+2", i, x)
+		}
+	}
+}
@@ -1,7 +1,5 @@
 package game
 
-import "log"
-
 // Genders is the list of globally available genders.
 var Genders []Gender
 
@@ -17,7 +15,7 @@ type Gender struct {
 // InitGenders sets up the globally available genders.
 func InitGenders(g []Gender) {
 	if len(g) == 0 {
-		log.Println("WARNING: no genders defined")
+		logger.Warn("no genders defined")
 		Genders = []Gender{
 			{
 				Name:  "none",
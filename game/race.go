@@ -0,0 +1,173 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Race is a kind of being characters can be, like an elf or a dwarf.
+type Race struct {
+	Name string
+	Desc string // a short description, shown when choosing a race
+
+	// Modifiers are added to a new character's attributes, by attribute name.
+	Modifiers map[string]int
+
+	// StartRoom is where characters of this race enter the world, unless
+	// their class says otherwise.  Zero means the MUD's start room.
+	StartRoom int
+
+	// Flags are the names of player flags new characters of this race start
+	// with.
+	Flags []string
+}
+
+// Races are all the races characters can choose from, in the order they're
+// offered.
+type Races []Race
+
+// Validate checks that the races make sense, and that their modifiers are for
+// the given attributes.  Modifier names are changed to match the case of the
+// attributes they're for.
+func (races Races) Validate(attrs Attributes) error {
+	seen := map[string]bool{}
+	for i := range races {
+		r := &races[i]
+		if r.Name == "" {
+			return fmt.Errorf("races must have a name")
+		}
+		if seen[strings.ToLower(r.Name)] {
+			return fmt.Errorf("race %q is defined more than once", r.Name)
+		}
+		seen[strings.ToLower(r.Name)] = true
+		mods, err := checkModifiers(r.Modifiers, attrs)
+		if err != nil {
+			return fmt.Errorf("race %q: %v", r.Name, err)
+		}
+		r.Modifiers = mods
+	}
+	return nil
+}
+
+// Find returns the race with the given name, ignoring case.
+func (races Races) Find(name string) (Race, bool) {
+	for _, r := range races {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Race{}, false
+}
+
+// Class is a calling characters can follow, like a warrior or a mage.
+type Class struct {
+	Name string
+	Desc string // a short description, shown when choosing a class
+
+	// Races are the names of the races that can be this class.  Empty means
+	// any race can.
+	Races []string
+
+	// Modifiers are added to a new character's attributes, on top of their
+	// race's, by attribute name.
+	Modifiers map[string]int
+
+	// StartRoom is where characters of this class enter the world.  Zero
+	// means their race's start room.
+	StartRoom int
+
+	// Flags are the names of player flags new characters of this class start
+	// with, along with their race's.
+	Flags []string
+}
+
+// Allows reports whether characters of the given race can be this class.
+func (c Class) Allows(race string) bool {
+	if len(c.Races) == 0 {
+		return true
+	}
+	for _, r := range c.Races {
+		if strings.EqualFold(r, race) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes are all the classes characters can choose from, in the order they're
+// offered.
+type Classes []Class
+
+// Validate checks that the classes make sense, that their modifiers are for
+// the given attributes, and that they only name the given races.  Every race
+// must be allowed at least one class.
+func (classes Classes) Validate(attrs Attributes, races Races) error {
+	seen := map[string]bool{}
+	for i := range classes {
+		c := &classes[i]
+		if c.Name == "" {
+			return fmt.Errorf("classes must have a name")
+		}
+		if seen[strings.ToLower(c.Name)] {
+			return fmt.Errorf("class %q is defined more than once", c.Name)
+		}
+		seen[strings.ToLower(c.Name)] = true
+		for _, name := range c.Races {
+			if _, ok := races.Find(name); !ok {
+				return fmt.Errorf("class %q allows race %q, which doesn't exist", c.Name, name)
+			}
+		}
+		mods, err := checkModifiers(c.Modifiers, attrs)
+		if err != nil {
+			return fmt.Errorf("class %q: %v", c.Name, err)
+		}
+		c.Modifiers = mods
+	}
+	if len(classes) == 0 {
+		return nil
+	}
+	for _, r := range races {
+		if len(classes.For(r.Name)) == 0 {
+			return fmt.Errorf("race %q isn't allowed any class", r.Name)
+		}
+	}
+	return nil
+}
+
+// Find returns the class with the given name, ignoring case.
+func (classes Classes) Find(name string) (Class, bool) {
+	for _, c := range classes {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Class{}, false
+}
+
+// For returns the classes characters of the given race can be.
+func (classes Classes) For(race string) Classes {
+	var allowed Classes
+	for _, c := range classes {
+		if c.Allows(race) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed
+}
+
+// checkModifiers returns the modifiers keyed by the names of the attributes
+// they're for, or an error if any aren't for an attribute.
+func checkModifiers(mods map[string]int, attrs Attributes) (map[string]int, error) {
+	if len(mods) == 0 {
+		return nil, nil
+	}
+	checked := make(map[string]int, len(mods))
+	for name, v := range mods {
+		a, ok := attrs.Find(name)
+		if !ok {
+			return nil, fmt.Errorf("modifier for attribute %q, which doesn't exist", name)
+		}
+		checked[a.Name] += v
+	}
+	return checked, nil
+}
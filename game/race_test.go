@@ -0,0 +1,81 @@
+package game
+
+import "testing"
+
+func testAttrs(t *testing.T) Attributes {
+	attrs := Attributes{
+		{Name: "STR", Min: 3, Max: 18},
+		{Name: "INT", Min: 3, Max: 18},
+	}
+	if err := attrs.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return attrs
+}
+
+func TestRacesValidate(t *testing.T) {
+	attrs := testAttrs(t)
+	races := Races{
+		{Name: "Elf", Modifiers: map[string]int{"int": 1, "Str": -1}},
+		{Name: "Dwarf"},
+	}
+	if err := races.Validate(attrs); err != nil {
+		t.Fatal(err)
+	}
+	if mods := races[0].Modifiers; mods["INT"] != 1 || mods["STR"] != -1 {
+		t.Errorf("expected modifiers keyed by attribute name, got %v", mods)
+	}
+	if r, ok := races.Find("elf"); !ok || r.Name != "Elf" {
+		t.Errorf("expected to find Elf by lowercase name, got %+v", r)
+	}
+
+	for _, bad := range []Races{
+		{{Name: ""}},
+		{{Name: "Elf"}, {Name: "elf"}},
+		{{Name: "Elf", Modifiers: map[string]int{"WIS": 1}}},
+	} {
+		if err := bad.Validate(attrs); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestClassesValidate(t *testing.T) {
+	attrs := testAttrs(t)
+	races := Races{{Name: "Elf"}, {Name: "Dwarf"}}
+	if err := races.Validate(attrs); err != nil {
+		t.Fatal(err)
+	}
+	classes := Classes{
+		{Name: "Warrior", Modifiers: map[string]int{"STR": 2}},
+		{Name: "Mage", Races: []string{"elf"}},
+	}
+	if err := classes.Validate(attrs, races); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(classes.For("Dwarf")); n != 1 {
+		t.Errorf("expected dwarves to have 1 class, got %d", n)
+	}
+	if n := len(classes.For("Elf")); n != 2 {
+		t.Errorf("expected elves to have 2 classes, got %d", n)
+	}
+
+	for _, bad := range []Classes{
+		{{Name: "Warrior"}, {Name: "WARRIOR"}},
+		{{Name: "Warrior", Races: []string{"Orc"}}},
+		{{Name: "Warrior", Modifiers: map[string]int{"WIS": 1}}},
+		{{Name: "Mage", Races: []string{"Elf"}}}, // no class for dwarves
+	} {
+		if err := bad.Validate(attrs, races); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+
+	// without races, classes can't be limited to any.
+	if err := (Classes{{Name: "Warrior"}}).Validate(attrs, nil); err != nil {
+		t.Errorf("expected classes without races to be valid, got %v", err)
+	}
+	if err := (Classes{{Name: "Mage", Races: []string{"Elf"}}}).Validate(attrs, nil); err == nil {
+		t.Error("expected an error for a class that allows a race when there are no races")
+	}
+}
@@ -0,0 +1,26 @@
+package game
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Rand is a source of random numbers that is safe for concurrent use.  Two
+// Rands made with the same seed return the same numbers when called in the same
+// order.
+type Rand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// NewRand returns a Rand seeded with the given value.
+func NewRand(seed int64) *Rand {
+	return &Rand{r: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a random number in [0,n).  It panics if n <= 0.
+func (r *Rand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Intn(n)
+}
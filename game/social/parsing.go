@@ -3,23 +3,27 @@ package social
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"text/template"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/natefinch/claymud/logging"
 )
 
 const (
 	templFile = "socials.toml"
 )
 
-var arrival *noTarget
+var (
+	arrival *noTarget
+	logger  = logging.For("social")
+)
 
 // DoArrival runs the standard social that occurs when you
-func DoArrival(actor Person, others io.Writer) {
-	performToNoOne("arrival", arrival, socialData{Actor: actor}, actor, others)
+func DoArrival(actor Person, setting Setting, others io.Writer) {
+	performToNoOne("arrival", arrival, socialData{Actor: actor, Setting: setting}, actor, others)
 }
 
 // Initialize creates the socialTemplate map and loads socials into it.
@@ -47,7 +51,7 @@ func Initialize(dir string) error {
 		return err
 	}
 
-	log.Printf("Loaded socials: %v", Names)
+	logger.Info("loaded socials", "names", Names)
 	return nil
 }
 
@@ -71,7 +75,7 @@ func decodeConfig(r io.Reader) (*socialConfig, error) {
 	}
 
 	if und := res.Undecoded(); len(und) > 0 {
-		log.Printf("WARNING: Unknown values in social config file: %v", und)
+		logger.Warn("unknown values in social config file", "keys", und)
 	}
 	return &cfg, nil
 }
@@ -59,7 +59,7 @@ func TestPerformOther(t *testing.T) {
 	}
 
 	others := &bytes.Buffer{}
-	found := Perform("smile", a, b, others)
+	found := Perform("smile", a, b, Setting{}, others)
 	if !found {
 		t.Fatal("smile social not found")
 	}
@@ -74,6 +74,29 @@ func TestPerformOther(t *testing.T) {
 
 }
 
+func TestPerformSetting(t *testing.T) {
+	cfg, err := decodeConfig(strings.NewReader(gaze))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadSocials(cfg.Socials); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		setting  Setting
+		expected string
+	}{
+		{Setting{Weather: game.WeatherRain, Outdoors: true}, "You gaze up at the rain."},
+		{Setting{Weather: game.WeatherRain}, "You stare at the ceiling."},
+	} {
+		a := testActor{name: "fooName", gender: female, buf: &bytes.Buffer{}}
+		Perform("gaze", a, nil, test.setting, &bytes.Buffer{})
+		if got := strings.TrimSpace(a.buf.String()); got != test.expected {
+			t.Errorf("expected %q with %+v, got %q", test.expected, test.setting, got)
+		}
+	}
+}
+
 /*
 func (*Tests) TestParse(c *C) {
 	ems, err := decodeSocials(strings.NewReader(data))
@@ -131,7 +154,7 @@ func (*Tests) TestPerformSelf(c *C) {
 	}
 
 	others := &bytes.Buffer{}
-	Perform("smile", a, a, others)
+	Perform("smile", a, a, Setting{}, others)
 
 	c.Assert(a.buf.String(), Equals, "You smile to yourself.")
 	c.Assert(others.String(), Equals, "fooName smiles to himself.")
@@ -225,3 +248,12 @@ target = "{{.Actor}} smiles at you."
 around = "{{.Actor}} smiles at {{.Target}}."
 
 `
+
+var gaze = `
+[[social]]
+name = "gaze"
+
+[social.toNoOne]
+self = "{{if .Outdoors}}You gaze up at the {{.Weather}}.{{else}}You stare at the ceiling.{{end}}"
+around = "{{.Actor.Name}} gazes upward."
+`
@@ -2,9 +2,9 @@ package social
 
 import (
 	"io"
-	"log"
 
 	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/util"
 )
 
@@ -54,17 +54,26 @@ type Person interface {
 	io.Writer
 }
 
+// Setting is when and where a social happens, so its text can mention the time
+// of day or the weather.
+type Setting struct {
+	Time     game.Time
+	Weather  game.Weather
+	Outdoors bool
+}
+
 // socialData is the data we pass into the templates to generate the text.
 type socialData struct {
 	Actor  Person
 	Target Person
+	Setting
 }
 
 // Perform attempts to perform the social named by cmd given the actor and target.
 // Target may be nil if no target was specified.
 // If the social exists, the output will be written to each of the writers.
 // Perform reports whether the social was found.
-func Perform(cmd string, actor Person, target Person, others io.Writer) bool {
+func Perform(cmd string, actor Person, target Person, setting Setting, others io.Writer) bool {
 	social, ok := socials[cmd]
 	if !ok {
 		return false
@@ -72,8 +81,9 @@ func Perform(cmd string, actor Person, target Person, others io.Writer) bool {
 
 	// TODO: Support more params?   Him/He/etc?
 	data := socialData{
-		Actor:  actor,
-		Target: target,
+		Actor:   actor,
+		Target:  target,
+		Setting: setting,
 	}
 
 	switch {
@@ -147,5 +157,5 @@ func performToOther(name string, toOther *withTarget, data socialData, actor Per
 }
 
 func logFillErr(name string, template string, data socialData, err error) {
-	log.Printf("ERROR: filling social template %q for %s with data %v: %s", name, template, data, err)
+	logger.Error("error filling social template", "social", name, "template", template, "data", data, logging.Err(err))
 }
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WorkerStats describes how busy a worker has been.
+type WorkerStats struct {
+	Name           string
+	Ticks          int64         // ticks run so far
+	Events         int64         // events handled so far
+	Overruns       int64         // ticks that took longer than TickLen
+	Panics         int64         // events that panicked
+	LastTickEvents int           // events handled in the last tick
+	LastTick       time.Duration // time spent working in the last tick
+	MaxTick        time.Duration // the longest time spent working in a tick
+	TotalTick      time.Duration // total time spent working
+	QueueWait      time.Duration // total time events waited to be handled
+	MaxQueueWait   time.Duration // the longest an event waited to be handled
+}
+
+// AvgTick returns the average time spent working each tick.
+func (s WorkerStats) AvgTick() time.Duration {
+	if s.Ticks == 0 {
+		return 0
+	}
+	return s.TotalTick / time.Duration(s.Ticks)
+}
+
+// AvgQueueWait returns the average time events waited to be handled.
+func (s WorkerStats) AvgQueueWait() time.Duration {
+	if s.Events == 0 {
+		return 0
+	}
+	return s.QueueWait / time.Duration(s.Events)
+}
+
+// Stats returns the worker's statistics.  It is safe to call from any
+// goroutine.
+func (w *Worker) Stats() WorkerStats {
+	w.statsMu.Lock()
+	s := w.stats
+	w.statsMu.Unlock()
+	s.Name = w.name
+	s.Panics = atomic.LoadInt64(&w.panics)
+	return s
+}
+
+// recordWait records how long an event waited before being handled.
+func (w *Worker) recordWait(wait time.Duration) {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	w.stats.Events++
+	w.stats.QueueWait += wait
+	if wait > w.stats.MaxQueueWait {
+		w.stats.MaxQueueWait = wait
+	}
+}
+
+// recordTick records how much work the worker did in a tick.
+func (w *Worker) recordTick(events int, took time.Duration, overrun bool) {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	w.stats.Ticks++
+	w.stats.LastTickEvents = events
+	w.stats.LastTick = took
+	w.stats.TotalTick += took
+	if took > w.stats.MaxTick {
+		w.stats.MaxTick = took
+	}
+	if overrun {
+		w.stats.Overruns++
+	}
+}
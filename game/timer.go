@@ -0,0 +1,101 @@
+package game
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Timer is an event scheduled to run on a worker at a later time.
+type Timer struct {
+	w     *Worker
+	at    time.Time     // when the timer next fires
+	every time.Duration // how often the timer repeats, zero for one-shot timers
+	fn    func()
+	index int // index in the worker's heap, -1 if not scheduled
+}
+
+// Cancel stops the timer from firing again.  It is safe to call more than once,
+// and from any goroutine, including from inside the timer's own function.
+func (t *Timer) Cancel() {
+	t.w.timerMu.Lock()
+	defer t.w.timerMu.Unlock()
+	t.every = 0
+	if t.index >= 0 {
+		heap.Remove(&t.w.timers, t.index)
+	}
+}
+
+// After runs fn on the worker's goroutine once d has passed on the worker's
+// clock.  Timers are checked once per tick, so they may run up to a tick late.
+func (w *Worker) After(d time.Duration, fn func()) *Timer {
+	return w.schedule(d, 0, fn)
+}
+
+// Every runs fn on the worker's goroutine every d, starting d from now, until
+// the timer is cancelled.
+func (w *Worker) Every(d time.Duration, fn func()) *Timer {
+	if d < TickLen {
+		d = TickLen
+	}
+	return w.schedule(d, d, fn)
+}
+
+func (w *Worker) schedule(d, every time.Duration, fn func()) *Timer {
+	t := &Timer{w: w, at: w.clock.Now().Add(d), every: every, fn: fn}
+	w.timerMu.Lock()
+	heap.Push(&w.timers, t)
+	w.timerMu.Unlock()
+	return t
+}
+
+// runTimers runs the timers that are due.  It must only be called by the
+// worker's goroutine.
+func (w *Worker) runTimers(now time.Time) {
+	for {
+		w.timerMu.Lock()
+		if len(w.timers) == 0 || w.timers[0].at.After(now) {
+			w.timerMu.Unlock()
+			return
+		}
+		t := w.timers[0]
+		if t.every > 0 {
+			// reschedule before running, so the timer can cancel itself.
+			t.at = t.at.Add(t.every)
+			if !t.at.After(now) {
+				// we fell behind, don't try to catch up.
+				t.at = now.Add(t.every)
+			}
+			heap.Fix(&w.timers, 0)
+		} else {
+			heap.Pop(&w.timers)
+		}
+		w.timerMu.Unlock()
+		w.exec(event{run: t.fn})
+	}
+}
+
+// timerHeap is a min-heap of timers ordered by when they fire.
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimers(t *testing.T) {
+	shutdown := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer close(shutdown)
+	w := SpawnWorker("test", &sync.Mutex{}, SystemClock, shutdown, wg)
+
+	fired := make(chan string, 10)
+	w.After(TickLen, func() { fired <- "once" })
+	cancelled := w.After(TickLen, func() { fired <- "cancelled" })
+	cancelled.Cancel()
+
+	// timers are normally set up by events on the worker itself.
+	w.Handle(func() {
+		n := 0
+		var every *Timer
+		every = w.Every(TickLen, func() {
+			n++
+			fired <- "every"
+			if n == 3 {
+				every.Cancel()
+			}
+		})
+	})
+
+	timeout := time.After(2 * time.Second)
+	counts := map[string]int{}
+	for counts["every"] < 3 || counts["once"] < 1 {
+		select {
+		case s := <-fired:
+			counts[s]++
+		case <-timeout:
+			t.Fatalf("timed out waiting for timers, got %v", counts)
+		}
+	}
+	// give the cancelled timers a chance to fire if they're going to.
+	time.Sleep(3 * TickLen)
+	if len(fired) != 0 || counts["cancelled"] != 0 || counts["once"] != 1 {
+		t.Fatalf("unexpected timers fired: %v, %d more", counts, len(fired))
+	}
+}
+
+func TestManualTimers(t *testing.T) {
+	clock := NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := NewManualWorker("test", &sync.Mutex{}, clock)
+
+	var fired []string
+	w.After(time.Second, func() { fired = append(fired, "after") })
+	w.Every(300*time.Millisecond, func() { fired = append(fired, "every") })
+
+	ran := false
+	w.Handle(func() { ran = true })
+	w.Step()
+	if !ran {
+		t.Fatal("expected the event to run on the first step")
+	}
+	for i := 0; i < 10; i++ {
+		clock.Advance(TickLen)
+		w.Step()
+	}
+	expected := "[every every every after]"
+	if s := fmt.Sprint(fired); s != expected {
+		t.Fatalf("expected %s, got %s", expected, s)
+	}
+}
@@ -0,0 +1,60 @@
+package game
+
+// Weather is the state of the sky over a zone.  It only gets better or worse
+// one step at a time, so a clear sky clouds over before it rains.
+type Weather int
+
+// All the kinds of weather, from best to worst.
+const (
+	WeatherClear Weather = iota
+	WeatherCloudy
+	WeatherRain
+	WeatherStorm
+)
+
+var weatherNames = [...]string{"clear", "cloudy", "rain", "storm"}
+
+// String returns the weather's name.
+func (w Weather) String() string {
+	if w < 0 || int(w) >= len(weatherNames) {
+		return "unknown"
+	}
+	return weatherNames[w]
+}
+
+// Chances that the weather gets worse or better each hour, in percent, before
+// wetness is taken into account.  Each point of wetness moves 10% from better
+// to worse.
+const (
+	weatherChange   = 20
+	wetnessPerPoint = 10
+	minChange       = 5
+	maxChange       = 50
+)
+
+// Next returns the weather an hour later.  The higher the wetness, the more
+// likely the weather gets worse rather than better.
+func (w Weather) Next(r *Rand, wetness int) Weather {
+	worse := clamp(weatherChange+wetness*wetnessPerPoint, minChange, maxChange)
+	better := clamp(weatherChange-wetness*wetnessPerPoint, minChange, maxChange)
+	roll := r.Intn(100)
+	switch {
+	case roll < worse && w < WeatherStorm:
+		return w + 1
+	case roll >= worse && roll < worse+better && w > WeatherClear:
+		return w - 1
+	default:
+		return w
+	}
+}
+
+func clamp(n, min, max int) int {
+	switch {
+	case n < min:
+		return min
+	case n > max:
+		return max
+	default:
+		return n
+	}
+}
@@ -1,52 +1,119 @@
 package game
 
 import (
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/natefinch/claymud/logging"
 )
 
-const tickLen = 100 * time.Millisecond
+var logger = logging.For("game")
+
+// TickLen is how long each tick of a worker lasts.
+const TickLen = 100 * time.Millisecond
+
+// Origin is whoever caused an event, such as a player typing a command.
+type Origin interface {
+	String() string
+
+	// Failed is called when the event panicked, so the origin can be told
+	// that something went wrong.
+	Failed()
+}
+
+// event is a function to run on the worker, and who asked for it.
+type event struct {
+	origin Origin
+	run    func()
+	queued time.Time // when the event was handed to the worker
+}
+
+// manualQueue is how many events can wait for a manual worker to be stepped.
+const manualQueue = 1024
 
 // SpawnWorker creates a long-lived goroutine that handles work until the
-// shutdown channel is closed.
-// Zone-local workers are spawned with a readlocker
-// so they can run in parallel.  A single global worker is spawned with a write
-// locker to ensure that none of the other workers are processing events when it
-// is (to avoid data races).  Closing the shutdown channel will stop the worker
-// as soon as possible.  Waiting on the waitgroup will unblock when all workers
-// have exited.
-func SpawnWorker(runLock sync.Locker, shutdown <-chan struct{}, wg *sync.WaitGroup) *Worker {
-	w := &Worker{
-		runLock:   runLock,
-		shutdown:  shutdown,
-		wg:        wg,
-		events:    make(chan func()),
-		next:      time.Now().Add(tickLen),
-		eventGate: &sync.RWMutex{},
-	}
+// shutdown channel is closed.  The name identifies the worker in logs, and the
+// clock decides how long a tick lasts.  Zone-local workers are spawned with a
+// readlocker so they can run in parallel.  A single global worker is spawned
+// with a write locker to ensure that none of the other workers are processing
+// events when it is (to avoid data races).  Closing the shutdown channel will
+// stop the worker as soon as possible.  Waiting on the waitgroup will unblock
+// when all workers have exited.
+func SpawnWorker(name string, runLock sync.Locker, clock Clock, shutdown <-chan struct{}, wg *sync.WaitGroup) *Worker {
+	w := newWorker(name, runLock, clock, make(chan event))
+	w.shutdown = shutdown
+	w.wg = wg
 	wg.Add(1)
 	go w.run()
 	return w
 }
 
+// NewManualWorker creates a worker that has no goroutine of its own.  Instead,
+// each call to Step runs a single tick, which lets tests and simulations decide
+// exactly when work happens.  Up to manualQueue events can be handled between
+// steps; handling more blocks until the worker is stepped.
+func NewManualWorker(name string, runLock sync.Locker, clock Clock) *Worker {
+	return newWorker(name, runLock, clock, make(chan event, manualQueue))
+}
+
+func newWorker(name string, runLock sync.Locker, clock Clock, events chan event) *Worker {
+	return &Worker{
+		name:      name,
+		runLock:   runLock,
+		clock:     clock,
+		events:    events,
+		next:      clock.Now().Add(TickLen),
+		eventGate: &sync.RWMutex{},
+	}
+}
+
 // Worker is a single-threaded event loop that serializes actions in the world.
 // All actions in a zone are handled by the same worker, eliminating race
 // conditions and obviating the need for most locks.  Coordination of actions
 // that take place across zones is done by a single "global" worker.  Since few
-// actions have that trait, this is less of a performance burden.
+// actions have that trait, this is less of a performance burden.  Each worker
+// also runs timers set with After and Every on its own goroutine, so delayed and
+// repeating events need no goroutines of their own.
 type Worker struct {
+	// OnPanic, if set, is called on the worker's goroutine after an event
+	// panics.  It must be set before any events are handled.
+	OnPanic func(origin Origin, recovered interface{})
+
+	name      string
+	panics    int64 // number of events that panicked, accessed atomically
 	shutdown  <-chan struct{}
 	wg        *sync.WaitGroup
+	clock     Clock
 	next      time.Time     // Next tick
 	runLock   sync.Locker   // exclusive lock between zone and global workers
 	eventGate *sync.RWMutex // exclusive lock between worker and event sources
-	events    chan func()
+	events    chan event
+
+	timerMu sync.Mutex
+	timers  timerHeap
+
+	postMu sync.Mutex
+	posted []event // events from Post, waiting for the next tick
+
+	statsMu sync.Mutex
+	stats   WorkerStats
 }
 
 // Handle takes an event from somewhere in the world and executes it.  This
 // method is thread safe, so users on their own threads can call it without
 // worry.
-func (w *Worker) Handle(event func()) {
+func (w *Worker) Handle(fn func()) {
+	w.HandleFrom(nil, fn)
+}
+
+// HandleFrom is like Handle, but records who caused the event, so they can be
+// told if it fails.  The origin may be nil.
+func (w *Worker) HandleFrom(origin Origin, fn func()) {
+	// queue wait is measured in real time, since it's about how busy the
+	// worker is, not how much game time has passed.
+	queued := time.Now()
 	// This is a gate that ensures each event source can only put one event on
 	// the worker's queue.
 	//
@@ -60,7 +127,90 @@ func (w *Worker) Handle(event func()) {
 	// channel.
 	w.eventGate.RLock()
 	w.eventGate.RUnlock()
-	w.events <- event
+	w.events <- event{origin: origin, run: fn, queued: queued}
+}
+
+// Post queues fn to run on the worker's goroutine during its next tick.  Unlike
+// Handle, it never blocks, so it is safe to call from inside any worker's
+// events, including this worker's own.  Posted events run in the order they
+// were posted.
+func (w *Worker) Post(fn func()) {
+	w.postMu.Lock()
+	w.posted = append(w.posted, event{run: fn, queued: time.Now()})
+	w.postMu.Unlock()
+}
+
+// runPosted runs the events queued by Post before this tick, returning how many
+// there were.  Events posted while they run wait for the next tick.  It must
+// only be called by the worker's goroutine.
+func (w *Worker) runPosted() int {
+	w.postMu.Lock()
+	posted := w.posted
+	w.posted = nil
+	w.postMu.Unlock()
+	for _, e := range posted {
+		w.recordWait(time.Since(e.queued))
+		w.exec(e)
+	}
+	return len(posted)
+}
+
+// Queued returns how many events are waiting for the worker's next tick: those
+// posted with Post, plus those handed to a manual worker that hasn't been
+// stepped.  Callers of Handle on a spawned worker wait for the worker instead of
+// queuing, so they aren't counted.  It is safe to call from any goroutine.
+func (w *Worker) Queued() int {
+	w.postMu.Lock()
+	n := len(w.posted)
+	w.postMu.Unlock()
+	return n + len(w.events)
+}
+
+// Timers returns how many timers are waiting to fire.  It is safe to call from
+// any goroutine.
+func (w *Worker) Timers() int {
+	w.timerMu.Lock()
+	defer w.timerMu.Unlock()
+	return len(w.timers)
+}
+
+// Panics returns how many events have panicked on this worker.
+func (w *Worker) Panics() int64 {
+	return atomic.LoadInt64(&w.panics)
+}
+
+// exec runs the event, recovering if it panics, so one broken event can't take
+// down the whole MUD.
+func (w *Worker) exec(e event) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		atomic.AddInt64(&w.panics, 1)
+		origin := "<unknown>"
+		if e.origin != nil {
+			origin = e.origin.String()
+		}
+		logger.Error("panic handling event", "worker", w.name, "origin", origin, "panic", r, "stack", string(debug.Stack()), logging.Event("panic"))
+		if e.origin != nil {
+			safely(w.name, e.origin.Failed)
+		}
+		if w.OnPanic != nil {
+			safely(w.name, func() { w.OnPanic(e.origin, r) })
+		}
+	}()
+	e.run()
+}
+
+// safely runs the function, logging rather than crashing if it panics.
+func safely(name string, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("panic while handling a panic", "worker", name, "panic", r, logging.Event("panic"))
+		}
+	}()
+	f()
 }
 
 // run is the goroutine for the worker.
@@ -71,30 +221,50 @@ func (w *Worker) run() {
 		if w.closed() {
 			return
 		}
-		func() {
-			defer w.runLock.Unlock()
-			w.runLock.Lock()
-			defer w.eventGate.Unlock()
-			w.eventGate.Lock()
-			for {
-				select {
-				case e := <-w.events:
-					e()
-				default:
-					return
-				}
-			}
-		}()
+		start := time.Now()
+		events := w.tick()
 		if w.closed() {
 			return
 		}
-		time.Sleep(time.Until(w.next))
+		sleep := w.next.Sub(w.clock.Now())
+		w.recordTick(events, time.Since(start), sleep < 0)
+		w.clock.Sleep(sleep)
 		if w.closed() {
 			return
 		}
 		// we recalculate next based on when we *should* have woken up, since
 		// the actual wake up time may vary slightly.
-		w.next = w.next.Add(tickLen)
+		w.next = w.next.Add(TickLen)
+	}
+}
+
+// Step runs a single tick of a worker made with NewManualWorker, handling all
+// waiting events and running any timers that are due by the worker's clock.
+// It must not be called on a worker made with SpawnWorker.
+func (w *Worker) Step() {
+	start := time.Now()
+	events := w.tick()
+	w.recordTick(events, time.Since(start), false)
+}
+
+// tick handles all the events waiting on the worker, then the posted events,
+// and runs any timers that are due.  It returns the number of events handled.
+func (w *Worker) tick() (events int) {
+	defer w.runLock.Unlock()
+	w.runLock.Lock()
+	defer w.eventGate.Unlock()
+	w.eventGate.Lock()
+	for {
+		select {
+		case e := <-w.events:
+			w.recordWait(time.Since(e.queued))
+			events++
+			w.exec(e)
+		default:
+			events += w.runPosted()
+			w.runTimers(w.clock.Now())
+			return events
+		}
 	}
 }
 
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type testOrigin struct {
+	failed chan struct{}
+}
+
+func (o testOrigin) String() string { return "test" }
+func (o testOrigin) Failed()        { close(o.failed) }
+
+func TestWorkerRecoversPanics(t *testing.T) {
+	shutdown := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer close(shutdown)
+
+	w := SpawnWorker("test", &sync.Mutex{}, SystemClock, shutdown, wg)
+	panicked := make(chan interface{}, 1)
+	w.OnPanic = func(_ Origin, r interface{}) {
+		panicked <- r
+	}
+
+	o := testOrigin{failed: make(chan struct{})}
+	w.HandleFrom(o, func() { panic("boom") })
+	<-o.failed
+	if r := <-panicked; r != "boom" {
+		t.Fatalf("expected OnPanic to get %q, got %v", "boom", r)
+	}
+
+	// the worker should still be running.
+	done := make(chan struct{})
+	w.Handle(func() { close(done) })
+	<-done
+	if n := w.Panics(); n != 1 {
+		t.Fatalf("expected 1 panic, got %d", n)
+	}
+}
+
+func TestWorkerStats(t *testing.T) {
+	shutdown := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer close(shutdown)
+
+	w := SpawnWorker("test", &sync.Mutex{}, SystemClock, shutdown, wg)
+	for i := 0; i < 3; i++ {
+		done := make(chan struct{})
+		w.Handle(func() { close(done) })
+		<-done
+	}
+	s := w.Stats()
+	if s.Name != "test" {
+		t.Errorf("expected name %q, got %q", "test", s.Name)
+	}
+	if s.Events != 3 {
+		t.Errorf("expected 3 events, got %d", s.Events)
+	}
+	if s.MaxQueueWait < s.AvgQueueWait() {
+		t.Errorf("max queue wait %v is less than the average %v", s.MaxQueueWait, s.AvgQueueWait())
+	}
+}
+
+func TestWorkerQueued(t *testing.T) {
+	clock := NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := NewManualWorker("test", &sync.Mutex{}, clock)
+	w.Handle(func() {})
+	w.Post(func() {})
+	w.Post(func() {})
+	w.After(time.Second, func() {})
+	if n := w.Queued(); n != 3 {
+		t.Errorf("expected 3 queued events, got %d", n)
+	}
+	if n := w.Timers(); n != 1 {
+		t.Errorf("expected 1 timer, got %d", n)
+	}
+	w.Step()
+	if n := w.Queued(); n != 0 {
+		t.Errorf("expected no queued events after a step, got %d", n)
+	}
+}
@@ -0,0 +1,285 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/natefinch/claymud/telnet"
+)
+
+// errNotSetup is returned when the MUD doesn't have an admin yet, since the
+// first account created would become the admin.
+var errNotSetup = errors.New("the MUD hasn't been set up, log in once to create the admin account first")
+
+// prompt is what the MUD sends when it's done answering a command.
+const prompt = "\n>"
+
+// things bots say.
+var phrases = []string{
+	"hello there",
+	"has anyone seen the mayor?",
+	"nice weather today",
+	"I think I'm lost",
+	"which way to the temple?",
+}
+
+// bot is a single simulated player.
+type bot struct {
+	cfg   Config
+	name  string
+	pass  string
+	stats *stats
+	rng   *rand.Rand
+
+	conn  net.Conn
+	buf   string   // output read but not yet matched
+	exits []string // the exits from the bot's room
+	said  int      // how many times the bot has talked
+}
+
+func newBot(cfg Config, name string, st *stats, rng *rand.Rand) *bot {
+	return &bot{
+		cfg:   cfg,
+		name:  name,
+		pass:  letters(rng.Int63()),
+		stats: st,
+		rng:   rng,
+	}
+}
+
+// login connects to the MUD and creates an account and character for the
+// bot, returning once the character is in the world.
+func (b *bot) login() error {
+	start := time.Now()
+	deadline := start.Add(b.cfg.Timeout)
+	conn, err := net.DialTimeout("tcp", b.cfg.Addr, b.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	// the telnet conn answers the server's option negotiation and strips it
+	// out of what we read.
+	b.conn = telnet.NewConn(conn)
+
+	_, i, err := b.expectAny(deadline, "Log in with existing account", "Greetings, Administrator.", "has not been set up")
+	if err != nil {
+		return err
+	}
+	if i != 0 {
+		return errNotSetup
+	}
+	steps := []struct{ expect, send string }{
+		{"", "c"},
+		{"Username: ", strings.ToLower(b.name)},
+		{"Password: ", b.pass},
+		{"By what name do you wish your character to be known? ", b.name},
+		{"What should this character's gender be?", "1"},
+	}
+	for _, s := range steps {
+		if s.expect != "" {
+			if _, err := b.expect(s.expect, deadline); err != nil {
+				return err
+			}
+		}
+		if err := b.send(s.send); err != nil {
+			return err
+		}
+	}
+	// MUDs with races, classes, or rolled attributes ask about them; take the
+	// first race and class, and keep the first roll.
+	for {
+		_, i, err = b.expectAny(deadline,
+			"What race should this character be?",
+			"What class should this character be?",
+			"Keep these attributes?",
+			"[Exits]")
+		if err != nil {
+			return err
+		}
+		if i == 3 {
+			break
+		}
+		answer := "1"
+		if i == 2 {
+			answer = "k"
+		}
+		if err := b.send(answer); err != nil {
+			return err
+		}
+	}
+	room, err := b.expect(prompt, deadline)
+	if err != nil {
+		return err
+	}
+	b.exits = parseExits(room)
+	b.stats.record(kindLogin, time.Since(start))
+	return nil
+}
+
+// play runs random commands until the deadline.
+func (b *bot) play(deadline time.Time) {
+	for {
+		// wait anywhere from half to one and a half times the interval, so
+		// the bots don't all act at once.
+		wait := b.cfg.Interval/2 + time.Duration(b.rng.Int63n(int64(b.cfg.Interval)))
+		if time.Now().Add(wait).After(deadline) {
+			return
+		}
+		time.Sleep(wait)
+		if err := b.act(); err != nil {
+			if isTimeout(err) {
+				b.stats.timeout()
+				// whatever shows up late shouldn't answer the next command.
+				b.buf = ""
+				continue
+			}
+			log.Printf("%s stopped: %v", b.name, err)
+			return
+		}
+	}
+}
+
+// act runs one random command, weighted by the config.
+func (b *bot) act() error {
+	n := b.rng.Intn(b.cfg.Walk + b.cfg.Say + b.cfg.Social)
+	switch {
+	case n < b.cfg.Walk:
+		return b.walk()
+	case n < b.cfg.Walk+b.cfg.Say:
+		return b.say()
+	default:
+		return b.social()
+	}
+}
+
+// walk moves through a random exit, or looks around if there aren't any.
+func (b *bot) walk() error {
+	cmd := "look"
+	if len(b.exits) > 0 {
+		cmd = strings.ToLower(b.exits[b.rng.Intn(len(b.exits))])
+	}
+	room, err := b.command(kindWalk, cmd, "[Exits]")
+	if err != nil {
+		return err
+	}
+	b.exits = parseExits(room)
+	return nil
+}
+
+// say says something to the room.  The count makes each message unique, so
+// the bot can tell its own from the other bots'.
+func (b *bot) say() error {
+	b.said++
+	msg := fmt.Sprintf("%s (%d)", phrases[b.rng.Intn(len(phrases))], b.said)
+	_, err := b.command(kindSay, "say "+msg, b.name+": "+msg)
+	return err
+}
+
+// social uses a random social with no target.  Socials tell the person using
+// them what they did in the second person, like "You smile.", which is how the
+// bot knows the answer is its own.
+func (b *bot) social() error {
+	s := b.cfg.Socials[b.rng.Intn(len(b.cfg.Socials))]
+	_, err := b.command(kindSocial, s, "You ")
+	return err
+}
+
+// command sends the line and waits for the marker that shows the MUD is
+// answering it, and then for the prompt.  It returns the output between the
+// two, and records how long it took.
+func (b *bot) command(kind int, line, marker string) (string, error) {
+	start := time.Now()
+	deadline := start.Add(b.cfg.Timeout)
+	if err := b.send(line); err != nil {
+		return "", err
+	}
+	if _, err := b.expect(marker, deadline); err != nil {
+		return "", err
+	}
+	out, err := b.expect(prompt, deadline)
+	if err != nil {
+		return "", err
+	}
+	b.stats.record(kind, time.Since(start))
+	return out, nil
+}
+
+// quit leaves the game and hangs up.
+func (b *bot) quit() {
+	b.send("quit")
+	b.send("y")
+	b.close()
+}
+
+func (b *bot) close() {
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+func (b *bot) send(line string) error {
+	_, err := fmt.Fprintf(b.conn, "%s\n", line)
+	return err
+}
+
+// expect reads until s shows up, and returns everything before it.
+func (b *bot) expect(s string, deadline time.Time) (string, error) {
+	out, _, err := b.expectAny(deadline, s)
+	return out, err
+}
+
+// expectAny reads until one of the strings shows up, and returns everything
+// before it and which one it was.  Output up to the end of the string is
+// consumed.
+func (b *bot) expectAny(deadline time.Time, ss ...string) (string, int, error) {
+	if err := b.conn.SetReadDeadline(deadline); err != nil {
+		return "", -1, err
+	}
+	p := make([]byte, 4096)
+	for {
+		first, which := -1, -1
+		for i, s := range ss {
+			if j := strings.Index(b.buf, s); j >= 0 && (first < 0 || j < first) {
+				first, which = j, i
+			}
+		}
+		if which >= 0 {
+			out := b.buf[:first]
+			b.buf = b.buf[first+len(ss[which]):]
+			return out, which, nil
+		}
+		n, err := b.conn.Read(p)
+		b.buf += string(p[:n])
+		b.stats.receive(n)
+		if err != nil {
+			return "", -1, err
+		}
+	}
+}
+
+// isTimeout reports whether the error is from a read taking too long.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// parseExits reads the directions out of the exits part of a room's
+// description, which look like "North - The Temple".
+func parseExits(room string) []string {
+	var exits []string
+	for _, line := range strings.Split(room, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			// the start of the next section, like [Players]
+			break
+		}
+		if i := strings.Index(line, " - "); i > 0 {
+			exits = append(exits, line[:i])
+		}
+	}
+	return exits
+}
@@ -0,0 +1,180 @@
+// Package loadtest connects bot players to a running MUD and measures how
+// quickly it answers them.  Each bot creates an account and a character, then
+// wanders through exits, talks, and uses socials until the test is over.
+package loadtest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls a load test.
+type Config struct {
+	Addr     string        // host:port of the MUD's telnet listener
+	Bots     int           // how many bots to connect
+	Duration time.Duration // how long the bots play, after they have all logged in
+	Ramp     time.Duration // how long to spread the logins out over
+	Interval time.Duration // average time between a bot's commands
+	Timeout  time.Duration // how long to wait for an answer to a command
+
+	// Walk, Say, and Social weight how often bots move, talk, and use a
+	// social.
+	Walk, Say, Social int
+
+	Socials []string // the socials bots use
+	Seed    int64    // seeds what the bots do, zero picks one based on the time
+}
+
+// Main runs a load test with the given command line arguments, and writes the
+// report to stdout.
+func Main(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	var cfg Config
+	var socials string
+	fs.StringVar(&cfg.Addr, "addr", "localhost:8888", "address of the MUD to test")
+	fs.IntVar(&cfg.Bots, "bots", 10, "number of bots to connect")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "how long the bots play once they are logged in")
+	fs.DurationVar(&cfg.Ramp, "ramp", 10*time.Second, "how long to spread out the bots' logins over")
+	fs.DurationVar(&cfg.Interval, "interval", time.Second, "average time between each bot's commands")
+	fs.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "how long to wait for the MUD to answer a command")
+	fs.IntVar(&cfg.Walk, "walk", 6, "how often bots move, relative to -say and -social")
+	fs.IntVar(&cfg.Say, "say", 2, "how often bots talk, relative to -walk and -social")
+	fs.IntVar(&cfg.Social, "social", 2, "how often bots use a social, relative to -walk and -say")
+	fs.StringVar(&socials, "socials", "smile", "comma separated socials the bots use")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "seed for what the bots do, 0 picks one based on the time")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	for _, s := range strings.Split(socials, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Socials = append(cfg.Socials, s)
+		}
+	}
+	r, err := Run(cfg)
+	if err != nil {
+		return err
+	}
+	r.Write(os.Stdout)
+	return nil
+}
+
+// Run runs a load test and returns the results.
+func Run(cfg Config) (*Report, error) {
+	if cfg.Bots < 1 {
+		return nil, fmt.Errorf("there must be at least one bot")
+	}
+	if cfg.Walk+cfg.Say+cfg.Social <= 0 {
+		return nil, fmt.Errorf("at least one of walk, say, and social must be more than zero")
+	}
+	if cfg.Social > 0 && len(cfg.Socials) == 0 {
+		return nil, fmt.Errorf("bots can't use socials without a list of socials")
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("the interval between commands must be more than zero")
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	// character names may only contain letters, so each run gets a few random
+	// letters to keep its names from clashing with earlier runs.
+	run := letters(rng.Int63n(26 * 26 * 26 * 26))
+	stats := newStats()
+
+	log.Printf("Connecting %d bots to %s (seed %d)", cfg.Bots, cfg.Addr, seed)
+	bots := make([]*bot, 0, cfg.Bots)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Bots; i++ {
+		if i > 0 && cfg.Ramp > 0 {
+			time.Sleep(cfg.Ramp / time.Duration(cfg.Bots))
+		}
+		b := newBot(cfg, "Bot"+run+letters(int64(i)), stats, rand.New(rand.NewSource(rng.Int63())))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.login(); err != nil {
+				stats.failLogin()
+				log.Printf("%s failed to log in: %v", b.name, err)
+				b.close()
+				return
+			}
+			mu.Lock()
+			bots = append(bots, b)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(bots) == 0 {
+		return nil, fmt.Errorf("none of the bots could log in")
+	}
+
+	log.Printf("%d bots logged in, playing for %v", len(bots), cfg.Duration)
+	stats.start()
+	deadline := time.Now().Add(cfg.Duration)
+	for _, b := range bots {
+		wg.Add(1)
+		go func(b *bot) {
+			defer wg.Done()
+			b.play(deadline)
+			b.quit()
+		}(b)
+	}
+	wg.Wait()
+	return stats.report(cfg.Bots), nil
+}
+
+// letters writes n in base 26 with the letters a to z, since character names
+// can't contain digits.
+func letters(n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	var b []byte
+	for {
+		b = append([]byte{byte('a' + n%26)}, b...)
+		n /= 26
+		if n == 0 {
+			return string(b)
+		}
+	}
+}
+
+// Write writes a readable version of the report.
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Bots: %d  Logged in: %d  Failed logins: %d\n", r.Bots, r.Bots-r.FailedLogins, r.FailedLogins)
+	fmt.Fprintf(w, "Played for %v: %d commands, %.1f commands/sec, %d timeouts, %d bytes received\n",
+		r.Elapsed.Round(time.Millisecond), r.Commands, r.Throughput(), r.Timeouts, r.Received)
+	fmt.Fprintf(w, "\n%-8s %8s %10s %10s %10s %10s\n", "Command", "Count", "p50", "p90", "p99", "Max")
+	for _, l := range r.Latencies {
+		if l.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %8d %10v %10v %10v %10v\n", l.Name, l.Count,
+			round(l.P50), round(l.P90), round(l.P99), round(l.Max))
+	}
+}
+
+// round makes a latency short enough to read in a table.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,62 @@
+package loadtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i > 0; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	l := summarize("walk", ds)
+	if l.Count != 100 {
+		t.Errorf("expected 100 latencies, got %d", l.Count)
+	}
+	for _, c := range []struct {
+		name     string
+		got, exp time.Duration
+	}{
+		{"p50", l.P50, 50 * time.Millisecond},
+		{"p90", l.P90, 90 * time.Millisecond},
+		{"p99", l.P99, 99 * time.Millisecond},
+		{"max", l.Max, 100 * time.Millisecond},
+	} {
+		if c.got != c.exp {
+			t.Errorf("expected %s of %v, got %v", c.name, c.exp, c.got)
+		}
+	}
+
+	l = summarize("say", []time.Duration{time.Second})
+	if l.P50 != time.Second || l.P99 != time.Second {
+		t.Errorf("expected a single latency to be every percentile, got %#v", l)
+	}
+}
+
+func TestParseExits(t *testing.T) {
+	room := `
+North - The Temple Of Midgaard
+Up - Limbo
+
+[Players]
+Bob - the wizard is standing here.
+`
+	exits := parseExits(room)
+	expected := []string{"North", "Up"}
+	if !reflect.DeepEqual(exits, expected) {
+		t.Errorf("expected %v, got %v", expected, exits)
+	}
+	if exits := parseExits("\nThere are no exits!\n"); len(exits) != 0 {
+		t.Errorf("expected no exits, got %v", exits)
+	}
+}
+
+func TestLetters(t *testing.T) {
+	for n, expected := range map[int64]string{0: "a", 25: "z", 26: "ba", 27: "bb"} {
+		if got := letters(n); got != expected {
+			t.Errorf("expected %d to be %q, got %q", n, expected, got)
+		}
+	}
+}
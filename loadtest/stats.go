@@ -0,0 +1,148 @@
+package loadtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// the kinds of things bots do, in the order they are reported.
+const (
+	kindLogin = iota
+	kindWalk
+	kindSay
+	kindSocial
+	numKinds
+)
+
+var kindNames = [numKinds]string{"login", "walk", "say", "social"}
+
+// stats collects what all the bots have seen.  It is safe to use from any
+// goroutine.
+type stats struct {
+	mu           sync.Mutex
+	latencies    [numKinds][]time.Duration
+	timeouts     int
+	failedLogins int
+	received     int64
+	started      time.Time
+}
+
+func newStats() *stats {
+	return &stats{}
+}
+
+// start marks the end of the logins and the start of play, so logins don't
+// count toward throughput.
+func (s *stats) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = time.Now()
+}
+
+// record records how long the MUD took to answer a command.
+func (s *stats) record(kind int, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[kind] = append(s.latencies[kind], d)
+}
+
+// timeout records a command that the MUD never answered.
+func (s *stats) timeout() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeouts++
+}
+
+// failLogin records a bot that couldn't log in.
+func (s *stats) failLogin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedLogins++
+}
+
+// receive records bytes read from the MUD.
+func (s *stats) receive(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received += int64(n)
+}
+
+// Report is the result of a load test.
+type Report struct {
+	Bots         int
+	FailedLogins int
+	Elapsed      time.Duration // how long the bots played, not counting logins
+	Commands     int           // commands answered while playing
+	Timeouts     int           // commands that weren't answered in time
+	Received     int64         // bytes read from the MUD
+
+	// Latencies are how long the MUD took from a command being sent to the
+	// prompt after its answer, for each kind of command and then all of them
+	// but logins together.
+	Latencies []Latency
+}
+
+// Throughput returns the commands answered per second.
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Commands) / r.Elapsed.Seconds()
+}
+
+// Latency summarizes how long one kind of command took.
+type Latency struct {
+	Name          string
+	Count         int
+	P50, P90, P99 time.Duration
+	Max           time.Duration
+}
+
+// report summarizes the stats.
+func (s *stats) report(bots int) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Report{
+		Bots:         bots,
+		FailedLogins: s.failedLogins,
+		Elapsed:      time.Since(s.started),
+		Timeouts:     s.timeouts,
+		Received:     s.received,
+	}
+	var all []time.Duration
+	for kind, ds := range s.latencies {
+		r.Latencies = append(r.Latencies, summarize(kindNames[kind], ds))
+		if kind != kindLogin {
+			all = append(all, ds...)
+			r.Commands += len(ds)
+		}
+	}
+	r.Latencies = append(r.Latencies, summarize("all", all))
+	return r
+}
+
+// summarize sorts the latencies and finds their percentiles.
+func summarize(name string, ds []time.Duration) Latency {
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	l := Latency{Name: name, Count: len(sorted)}
+	if len(sorted) == 0 {
+		return l
+	}
+	l.P50 = percentile(sorted, 50)
+	l.P90 = percentile(sorted, 90)
+	l.P99 = percentile(sorted, 99)
+	l.Max = sorted[len(sorted)-1]
+	return l
+}
+
+// percentile returns the smallest latency that p percent of the sorted
+// latencies are at or below.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1]
+}
@@ -0,0 +1,211 @@
+// Package logging sets up ClayMUD's structured logs.  Each part of the MUD
+// logs through its own subsystem logger from For, and each subsystem can have
+// its own level, so an admin can turn up the logging for the part of the MUD
+// they're interested in without drowning in the rest.
+//
+// Log lines use the same keys for the same things everywhere, so logs can be
+// searched reliably.  Use the attribute functions in this package, like Player
+// and Room, rather than writing the keys by hand.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/natefinch/claymud/util"
+)
+
+// The keys used for common fields in log lines.
+const (
+	SubsystemKey = "subsystem"
+	PlayerKey    = "player"
+	UserKey      = "user"
+	ZoneKey      = "zone"
+	RoomKey      = "room"
+	AddrKey      = "addr"
+	EventKey     = "event"
+	ErrorKey     = "err"
+)
+
+// Config configures the logs.
+type Config struct {
+	Format string            // "text" or "json", text if empty
+	Level  string            // the level for subsystems not in Levels, info if empty
+	Levels map[string]string // levels for individual subsystems
+}
+
+var (
+	// base is the handler all the subsystems write to.  It is swapped out by
+	// Setup, so it is stored atomically.
+	base atomic.Value // of handlerBox
+
+	mu         sync.Mutex
+	subsystems = map[string]*slog.LevelVar{}
+	levels     = map[string]slog.Level{}
+	defLevel   = slog.LevelInfo
+)
+
+// handlerBox lets handlers of different types be stored in an atomic.Value.
+type handlerBox struct{ h slog.Handler }
+
+func init() {
+	base.Store(handlerBox{newHandler(os.Stderr, "text")})
+}
+
+// Setup makes all the subsystems write to w with the given format and levels.
+// It also sends anything written with the standard log package through the
+// "mud" subsystem.
+func Setup(w io.Writer, cfg Config) error {
+	format := strings.ToLower(cfg.Format)
+	switch format {
+	case "":
+		format = "text"
+	case "text", "json":
+	default:
+		return fmt.Errorf("Logging.Format must be text or json, but got %q", cfg.Format)
+	}
+	def := slog.LevelInfo
+	if cfg.Level != "" {
+		if err := def.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return fmt.Errorf("bad Logging.Level: %v", err)
+		}
+	}
+	lvls := map[string]slog.Level{}
+	for name, l := range cfg.Levels {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(l)); err != nil {
+			return fmt.Errorf("bad level for subsystem %q: %v", name, err)
+		}
+		lvls[strings.ToLower(name)] = lvl
+	}
+
+	mu.Lock()
+	defLevel = def
+	levels = lvls
+	for name, v := range subsystems {
+		v.Set(levelFor(name))
+	}
+	mu.Unlock()
+
+	base.Store(handlerBox{newHandler(w, format)})
+	slog.SetDefault(For("mud"))
+	return nil
+}
+
+// newHandler returns a handler that writes everything, since the subsystems
+// decide what's enabled.
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// levelFor returns the configured level for the subsystem.  It must be called
+// with mu held.
+func levelFor(name string) slog.Level {
+	if l, ok := levels[name]; ok {
+		return l
+	}
+	return defLevel
+}
+
+// For returns the logger for the named subsystem.  Every line it writes has
+// the subsystem's name, and its level can be set in the config.  It is safe to
+// call before Setup, such as to set a package variable.
+func For(subsystem string) *slog.Logger {
+	name := strings.ToLower(subsystem)
+	mu.Lock()
+	v, ok := subsystems[name]
+	if !ok {
+		v = &slog.LevelVar{}
+		v.Set(levelFor(name))
+		subsystems[name] = v
+	}
+	mu.Unlock()
+	h := &handler{level: v}
+	return slog.New(h.WithAttrs([]slog.Attr{slog.String(SubsystemKey, name)}))
+}
+
+// handler filters a subsystem's logs by its level, and passes the rest on to
+// whatever the base handler is when the line is written.
+type handler struct {
+	level *slog.LevelVar
+	// ops are the WithAttrs and WithGroup calls made on this handler, which
+	// are applied to the base handler when a line is written, since the base
+	// may have changed since they were made.
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	b := base.Load().(handlerBox).h
+	for _, op := range h.ops {
+		b = op(b)
+	}
+	return b.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(b slog.Handler) slog.Handler { return b.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(b slog.Handler) slog.Handler { return b.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{level: h.level, ops: append(ops, op)}
+}
+
+// Player is the name of the player a log line is about.
+func Player(name string) slog.Attr {
+	return slog.String(PlayerKey, name)
+}
+
+// User is the username of the user a log line is about.
+func User(username string) slog.Attr {
+	return slog.String(UserKey, username)
+}
+
+// Zone is the ID of the zone a log line is about.
+func Zone(id util.ID) slog.Attr {
+	return slog.Uint64(ZoneKey, uint64(id))
+}
+
+// Room is the ID of the room a log line is about.
+func Room(id util.ID) slog.Attr {
+	return slog.Uint64(RoomKey, uint64(id))
+}
+
+// Addr is the remote address of the connection a log line is about.
+func Addr(addr net.Addr) slog.Attr {
+	if addr == nil {
+		return slog.String(AddrKey, "")
+	}
+	return slog.String(AddrKey, addr.String())
+}
+
+// Event is what kind of thing happened, such as "login" or "panic", so
+// similar lines can be found together.
+func Event(name string) slog.Attr {
+	return slog.String(EventKey, name)
+}
+
+// Err is the error that went with a log line.
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
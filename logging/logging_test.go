@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestJSONFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	// set up before and after getting the logger, to show the logger follows
+	// the current setup.
+	if err := Setup(buf, Config{Format: "text"}); err != nil {
+		t.Fatal(err)
+	}
+	l := For("Test").With(Zone(3))
+	if err := Setup(buf, Config{Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4000}
+	l.Info("hi", Player("Bob"), User("bob"), Room(100), Addr(addr), Event("login"), Err(errors.New("oops")))
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", buf, err)
+	}
+	expected := map[string]interface{}{
+		"msg":        "hi",
+		SubsystemKey: "test",
+		PlayerKey:    "Bob",
+		UserKey:      "bob",
+		ZoneKey:      float64(3),
+		RoomKey:      float64(100),
+		AddrKey:      "127.0.0.1:4000",
+		EventKey:     "login",
+		ErrorKey:     "oops",
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("expected %s to be %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	quiet := For("quiet")
+	err := Setup(buf, Config{
+		Level:  "warn",
+		Levels: map[string]string{"Loud": "debug", "quiet": "error"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loud := For("loud")
+	other := For("other")
+
+	loud.Debug("loud debug")
+	other.Info("other info")
+	other.Warn("other warn")
+	quiet.Warn("quiet warn")
+	quiet.Error("quiet error")
+
+	out := buf.String()
+	for _, s := range []string{"loud debug", "other warn", "quiet error"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q to be logged, got:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{"other info", "quiet warn"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected %q not to be logged, got:\n%s", s, out)
+		}
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{Format: "xml"},
+		{Level: "loud"},
+		{Levels: map[string]string{"world": "loud"}},
+	} {
+		if err := Setup(&bytes.Buffer{}, cfg); err == nil {
+			t.Errorf("expected an error from %+v", cfg)
+		}
+	}
+}
@@ -3,19 +3,23 @@ package config
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/natefinch/claymud/world"
 
 	"github.com/BurntSushi/toml"
 	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/util"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+var logger = logging.For("server")
+
 // Init sets up the application's configuration directory.
 func Init() (*Config, error) {
 	dataDir := getDataDir()
@@ -33,9 +37,20 @@ func Init() (*Config, error) {
 	// set some defaults
 	cfg := Config{
 		BcryptCost: 10,
-		Logging:    &lumberjack.Logger{Filename: logfile},
 	}
+	cfg.Logging.Filename = logfile
 	cfg.ChatMode.Enabled = "allow"
+	cfg.Input.MaxLineLength = 1024
+	cfg.Input.QueueSize = 20
+	cfg.Input.CommandsPerTick = 1
+	cfg.Input.SpamLimit = 20
+	cfg.Output.BufferSize = 64 * 1024
+	cfg.Output.StallTimeout.Duration = time.Minute
+	cfg.Time.HourLength.Duration = game.DefaultCalendar.HourLength
+	cfg.Time.HoursPerDay = game.DefaultCalendar.HoursPerDay
+	cfg.Time.DaysPerMonth = game.DefaultCalendar.DaysPerMonth
+	cfg.Time.Sunrise = game.DefaultCalendar.Sunrise
+	cfg.Time.Sunset = game.DefaultCalendar.Sunset
 	cfgFile := filepath.Join(dataDir, "mud.toml")
 	md, err := toml.DecodeFile(cfgFile, &cfg)
 	if err != nil {
@@ -48,25 +63,64 @@ func Init() (*Config, error) {
 		return nil, fmt.Errorf("ChatMode.Enabled must be allow, deny, or require, but got %q", cfg.ChatMode.Enabled)
 	}
 	if len(md.Undecoded()) > 0 {
-		log.Printf("WARNING: unrecognized values in mud.toml: %v", md.Undecoded())
+		logger.Warn("unrecognized values in mud.toml", "keys", md.Undecoded())
 	}
 
 	// ignore any data dir specified in the config... you can't really set it there
 	cfg.DataDir = dataDir
 
+	if cfg.TLS.Port != 0 {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, fmt.Errorf("TLS.CertFile and TLS.KeyFile must be set when TLS.Port is set")
+		}
+		cfg.TLS.CertFile = inDataDir(dataDir, cfg.TLS.CertFile)
+		cfg.TLS.KeyFile = inDataDir(dataDir, cfg.TLS.KeyFile)
+	}
+	if cfg.Debug.Port != 0 && cfg.Debug.Token == "" {
+		return nil, fmt.Errorf("Debug.Token must be set when Debug.Port is set")
+	}
+	if cfg.SSH.HostKeyFile == "" {
+		cfg.SSH.HostKeyFile = "ssh_host_key"
+	}
+	cfg.SSH.HostKeyFile = inDataDir(dataDir, cfg.SSH.HostKeyFile)
+
 	cmdFile := filepath.Join(dataDir, "commands.toml")
 	md, err = toml.DecodeFile(cmdFile, &cfg.Commands)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing config file %q: %v", cfgFile, err)
 	}
 	if len(md.Undecoded()) > 0 {
-		log.Printf("WARNING: unrecognized values in commands.toml: %v", md.Undecoded())
+		logger.Warn("unrecognized values in commands.toml", "keys", md.Undecoded())
+	}
+
+	// attributes, races, and classes are optional, so a MUD can do without
+	// them.
+	var attrs struct {
+		Attribute game.Attributes
+	}
+	if err := decodeOptional(dataDir, "attributes.toml", &attrs); err != nil {
+		return nil, err
 	}
+	cfg.Attributes = attrs.Attribute
+	var races struct {
+		Race game.Races
+	}
+	if err := decodeOptional(dataDir, "races.toml", &races); err != nil {
+		return nil, err
+	}
+	cfg.Races = races.Race
+	var classes struct {
+		Class game.Classes
+	}
+	if err := decodeOptional(dataDir, "classes.toml", &classes); err != nil {
+		return nil, err
+	}
+	cfg.Classes = classes.Class
 
-	if err := configLogging(cfg.Logging); err != nil {
+	if err := configLogging(&cfg.Logging); err != nil {
 		return nil, err
 	}
-	log.Printf("Using data directory %s", dataDir)
+	logger.Info("using data directory", "dir", dataDir)
 
 	return &cfg, nil
 }
@@ -77,15 +131,86 @@ type Config struct {
 	StartRoom  int    // the starting room number
 	MainTitle  string // title screen
 	BcryptCost int    // work factor for auth
-	Logging    *lumberjack.Logger
+	Logging    Logging
 	ChatMode   struct {
 		Enabled string // "allow" "deny" or "require"
 		Default bool   // whether chatmode starts enabled or not
 		Prefix  string // if not "deny", commands other than movement must start with a prefix
 	}
-	Direction []game.Direction
-	Gender    []game.Gender
-	Commands  world.Commands
+	Idle struct {
+		Warn     util.Duration // how long until idle players are warned
+		Void     util.Duration // how long until idle players are moved to the void
+		Timeout  util.Duration // how long until idle players are disconnected
+		VoidRoom int           // the room number of the void
+	}
+	LinkDead struct {
+		Grace util.Duration // how long players who lose their connection stay in the world
+	}
+	Input struct {
+		MaxLineLength   int // longer lines are truncated
+		QueueSize       int // how many commands can wait to be run for each player
+		CommandsPerTick int // how many commands each player can run per tick
+		SpamLimit       int // how many commands can be dropped from a full queue before disconnecting
+	}
+	Output struct {
+		BufferSize   int           // how many bytes of output can wait to be sent to each player
+		StallTimeout util.Duration // how long a player's connection can block before they are dropped
+	}
+	MSSP struct {
+		Enabled bool              // whether to answer MSSP requests from MUD crawlers
+		Name    string            // the name of the MUD as shown in MUD listings
+		Fields  map[string]string // additional MSSP variables, e.g. CONTACT or WEBSITE
+	}
+	TLS struct {
+		Port         int    // port for TLS connections, 0 means TLS is disabled
+		CertFile     string // path to the PEM encoded certificate
+		KeyFile      string // path to the PEM encoded private key
+		DisablePlain bool   // if true, don't listen for unencrypted connections
+	}
+	SSH struct {
+		Port        int    // port for ssh connections, 0 means ssh is disabled
+		HostKeyFile string // path to the server's private host key
+	}
+	Web struct {
+		Port int // port for the web client, 0 means the web client is disabled
+	}
+	Metrics struct {
+		Port int // localhost port for prometheus metrics, 0 means metrics are disabled
+	}
+	Debug struct {
+		Port  int    // localhost port for the debug server, 0 means it is disabled
+		Token string // the token requests to the debug server must include
+	}
+	Time struct {
+		HourLength   util.Duration // how much real time a game hour lasts
+		HoursPerDay  int           // how many hours are in a game day
+		DaysPerMonth int           // how many days are in a game month
+		Sunrise      int           // the hour the sun comes up
+		Sunset       int           // the hour the sun goes down
+		Months       []string      // the names of the months, in order
+		Season       []game.Season // the seasons of the year and their weather
+	}
+	Direction  []game.Direction
+	Gender     []game.Gender
+	Commands   world.Commands
+	Attributes game.Attributes `toml:"-"` // from attributes.toml
+	Races      game.Races      `toml:"-"` // from races.toml
+	Classes    game.Classes    `toml:"-"` // from classes.toml
+}
+
+// decodeOptional decodes the named toml file in the data directory into v, if
+// the file exists.
+func decodeOptional(dataDir, name string, v interface{}) error {
+	file := filepath.Join(dataDir, name)
+	md, err := toml.DecodeFile(file, v)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("error parsing config file %q: %v", file, err)
+	case len(md.Undecoded()) > 0:
+		logger.Warn("unrecognized values in "+name, "keys", md.Undecoded())
+	}
+	return nil
 }
 
 // getDataDir returns the platform-specific data directory.
@@ -102,10 +227,32 @@ func getDataDir() string {
 	return filepath.Join(os.Getenv("HOME"), ".config", "claymud")
 }
 
-func configLogging(lj *lumberjack.Logger) error {
-	log.Printf("Logging to %s", lj.Filename)
-	log.SetOutput(io.MultiWriter(lj, os.Stdout))
+// inDataDir returns the path relative to the data dir if it is not absolute.
+func inDataDir(dataDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dataDir, path)
+}
 
-	log.Println("******************* ClayMUD Starting *******************")
+// Logging configures where logs are written and what they look like.  The
+// rotating log file is configured with the fields of the lumberjack Logger.
+type Logging struct {
+	lumberjack.Logger
+	Format string            // "text" or "json"
+	Level  string            // debug, info, warn, or error
+	Levels map[string]string // levels for individual subsystems, like world or auth
+}
+
+func configLogging(cfg *Logging) error {
+	err := logging.Setup(io.MultiWriter(&cfg.Logger, os.Stdout), logging.Config{
+		Format: cfg.Format,
+		Level:  cfg.Level,
+		Levels: cfg.Levels,
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("******************* ClayMUD Starting *******************", "file", cfg.Filename)
 	return nil
 }
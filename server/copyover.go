@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/telnet"
+	"github.com/natefinch/claymud/util"
+	"github.com/natefinch/claymud/world"
+)
+
+// copyoverEnv is the environment variable that tells a rebooted process where
+// to find the state handed over by the old process.
+const copyoverEnv = "CLAYMUD_COPYOVER"
+
+// rebootExec replaces this process with a new copy of the program.  Tests
+// replace it to see what would have been handed off.
+var rebootExec = execSelf
+
+// flushTimeout is how long a reboot waits for output queued for the players to
+// be sent, since anything still queued is lost when the process is replaced.
+const flushTimeout = 2 * time.Second
+
+// copyoverState is what the old process hands to the new one when the MUD
+// reboots.  File descriptors are inherited across exec.
+type copyoverState struct {
+	Listeners map[int]uintptr // listening sockets by port
+	Players   []copyoverPlayer
+}
+
+// copyoverPlayer is a player whose connection survives the reboot.
+type copyoverPlayer struct {
+	Username string
+	Player   string
+	Room     util.ID
+	FD       uintptr
+	Addr     string
+	Width    int
+	Height   int
+}
+
+var (
+	// listeners holds everything we're listening on by port, so the listeners
+	// can be handed to the new process on reboot.
+	listenersMu sync.Mutex
+	listeners   = map[int]*net.TCPListener{}
+
+	// inherited holds listeners handed to us by the process before a reboot.
+	inherited map[int]uintptr
+)
+
+// listen starts listening for TCP connections on the given port.  If the
+// process before a reboot was listening on the port, its listener is reused.
+func listen(port int) (*net.TCPListener, error) {
+	l, err := inheritedListener(port)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+		if err != nil {
+			return nil, err
+		}
+		l, err = net.ListenTCP("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	listenersMu.Lock()
+	listeners[port] = l
+	listenersMu.Unlock()
+	return l, nil
+}
+
+// inheritedListener returns the listener for the port from before a reboot, or
+// nil if there isn't one.
+func inheritedListener(port int) (*net.TCPListener, error) {
+	fd, ok := inherited[port]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited, port)
+	f := os.NewFile(fd, "listener:"+strconv.Itoa(port))
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't reuse listener for port %d after reboot: %v", port, err)
+	}
+	tl, ok := l.(*net.TCPListener)
+	if !ok {
+		l.Close()
+		return nil, fmt.Errorf("inherited listener for port %d is not TCP", port)
+	}
+	logger.Info("reusing listener from before the reboot", "port", port)
+	return tl, nil
+}
+
+// loadCopyover reads the state handed over from the process before a reboot,
+// if this process was started by a reboot.
+func loadCopyover() (*copyoverState, error) {
+	filename := os.Getenv(copyoverEnv)
+	if filename == "" {
+		return nil, nil
+	}
+	os.Unsetenv(copyoverEnv)
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("can't read reboot state: %v", err)
+	}
+	os.Remove(filename)
+	state := &copyoverState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("can't parse reboot state: %v", err)
+	}
+	inherited = state.Listeners
+	logger.Info("restarting after reboot", "players", len(state.Players), logging.Event("reboot"))
+	return state, nil
+}
+
+// restorePlayers puts the players from before a reboot back in the world.  It
+// must be called after all the listeners have been started.
+func restorePlayers(state *copyoverState, mssp func() []telnet.Var, svc *auth.Service, st *db.Store, wld *world.World) {
+	// close any listeners the new configuration doesn't use.
+	for port, fd := range inherited {
+		logger.Info("closing listener that is no longer configured", "port", port)
+		os.NewFile(fd, "listener:"+strconv.Itoa(port)).Close()
+	}
+	inherited = nil
+
+	for _, cp := range state.Players {
+		f := os.NewFile(cp.FD, cp.Addr)
+		conn, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			logger.Error("can't restore connection after reboot", logging.User(cp.Username), logging.Err(err))
+			continue
+		}
+		tc := telnet.NewConn(conn)
+		tc.MSSP = mssp
+		tc.SetSize(cp.Width, cp.Height)
+		user, err := svc.Resume(st, tc, cp.Username)
+		if err != nil {
+			logger.Error("can't restore user after reboot", logging.User(cp.Username), logging.Err(err))
+			io.WriteString(tc, "Sorry, we lost track of you during the reboot.  Please log in again.\n")
+			tc.Close()
+			continue
+		}
+		go func(cp copyoverPlayer) {
+			if err := wld.RestorePlayer(st, user, cp.Player, cp.Room); err != nil {
+				logger.Error("can't restore player after reboot", logging.User(cp.Username), logging.Player(cp.Player), logging.Err(err))
+				user.Close()
+			}
+		}(cp)
+	}
+}
+
+// reboot hands the listeners and player connections to a new copy of this
+// program.  It only returns if the reboot failed.
+func reboot(dataDir string, wld *world.World) error {
+	state := copyoverState{Listeners: map[int]uintptr{}}
+	var fds []uintptr
+	defer func() {
+		// we only get here if the new process didn't start.
+		for _, fd := range fds {
+			closeFD(fd)
+		}
+	}()
+
+	listenersMu.Lock()
+	for port, l := range listeners {
+		fd, err := dupFD(l)
+		if err != nil {
+			listenersMu.Unlock()
+			return fmt.Errorf("can't hand off listener on port %d: %v", port, err)
+		}
+		fds = append(fds, fd)
+		state.Listeners[port] = fd
+	}
+	listenersMu.Unlock()
+
+	handoffs := wld.Handoffs()
+	// players whose connections can't be handed off, who will be told to log
+	// in again.
+	var dropped []world.Handoff
+	for _, h := range handoffs {
+		tc, ok := h.Conn.(*telnet.Conn)
+		var tcp *net.TCPConn
+		if ok {
+			tcp, ok = tc.Conn.(*net.TCPConn)
+		}
+		if !ok {
+			// TLS, ssh, and websocket connections have state we can't hand off.
+			dropped = append(dropped, h)
+			continue
+		}
+		fd, err := dupFD(tcp)
+		if err != nil {
+			logger.Error("can't hand off connection", logging.User(h.Username), logging.Player(h.Player), logging.Err(err))
+			continue
+		}
+		fds = append(fds, fd)
+		w, ht := tc.Size()
+		state.Players = append(state.Players, copyoverPlayer{
+			Username: h.Username,
+			Player:   h.Player,
+			Room:     h.Room,
+			FD:       fd,
+			Addr:     tcp.RemoteAddr().String(),
+			Width:    w,
+			Height:   ht,
+		})
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(dataDir, "copyover.json")
+	if err := os.WriteFile(filename, b, 0600); err != nil {
+		return fmt.Errorf("can't write reboot state: %v", err)
+	}
+	if err := os.Setenv(copyoverEnv, filename); err != nil {
+		os.Remove(filename)
+		return err
+	}
+
+	// only the exec is left to fail, so it's time to tell the players who
+	// can't come along.
+	for _, h := range dropped {
+		io.WriteString(h.User, "Your connection can't survive the reboot.  Please log in again in a moment.\n")
+	}
+	deadline := time.Now().Add(flushTimeout)
+	for _, h := range handoffs {
+		if err := h.User.Flush(time.Until(deadline)); err != nil {
+			logger.Warn("output lost in the reboot", logging.User(h.Username), logging.Player(h.Player), logging.Err(err))
+		}
+	}
+
+	logger.Info("rebooting", "listeners", len(state.Listeners), "players", len(state.Players), logging.Event("reboot"))
+	err = rebootExec()
+	os.Unsetenv(copyoverEnv)
+	os.Remove(filename)
+	// the reboot failed, but the players who were told to log in again expect
+	// to be disconnected.
+	for _, h := range dropped {
+		h.User.Close()
+	}
+	return err
+}
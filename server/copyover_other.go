@@ -0,0 +1,20 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errNoReboot = errors.New("reboot is not supported on this platform")
+
+func dupFD(syscall.Conn) (uintptr, error) {
+	return 0, errNoReboot
+}
+
+func closeFD(uintptr) {}
+
+func execSelf() error {
+	return errNoReboot
+}
@@ -0,0 +1,282 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/game/social"
+	"github.com/natefinch/claymud/world"
+)
+
+// fixtureDir is the world package's tiny test world.  Room 100 is the Town
+// Square, where players start, and room 102 is the Town Hall.
+const fixtureDir = "../world/testdata"
+
+func TestRebootAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wld, svc, st, stop := newTestWorld(t, dir, "Alice", "Bob")
+	defer stop()
+
+	// Bob is on a plain telnet connection, which survives the reboot.  Ghost
+	// has no account, so can't be restored.
+	bobConn, bobFD := dialFD(t)
+	ghostConn, ghostFD := dialFD(t)
+	inherited = map[int]uintptr{}
+	defer func() { inherited = nil }()
+	restorePlayers(&copyoverState{Players: []copyoverPlayer{
+		{Username: "bob", Player: "Bob", Room: 102, FD: bobFD, Addr: "bob", Width: 100, Height: 40},
+		{Username: "ghost", Player: "Ghost", Room: 100, FD: ghostFD, Addr: "ghost"},
+	}}, nil, svc, st, wld)
+	bob := record(bobConn, 0)
+	ghost := record(ghostConn, 0)
+	bob.expect(t, "Town Hall")
+	ghost.expect(t, "Sorry, we lost track of you during the reboot.")
+	ghost.expectClosed(t)
+
+	// Alice's connection isn't telnet over TCP, so Alice has to log in again.
+	// Alice reads slowly, so the reboot has to wait for Alice's output.
+	server, aliceConn := net.Pipe()
+	user, err := svc.Resume(st, server, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go wld.RestorePlayer(st, user, "Alice", 100)
+	alice := record(aliceConn, 20*time.Millisecond)
+	alice.expect(t, "Town Square")
+
+	var state *copyoverState
+	var queued []string
+	rebootExec = func() error {
+		for _, h := range wld.Handoffs() {
+			if s := h.User.OutputStats(); s.Queued > 0 || s.Stalled > 0 {
+				queued = append(queued, h.Player)
+			}
+		}
+		var err error
+		if state, err = loadCopyover(); err != nil {
+			t.Error(err)
+		}
+		return errors.New("exec failed")
+	}
+	defer func() { rebootExec = execSelf }()
+
+	if err := reboot(dir, wld); err == nil || err.Error() != "exec failed" {
+		t.Fatalf("expected the exec to fail, got %v", err)
+	}
+	if len(queued) > 0 {
+		t.Errorf("expected all output to be sent before the exec, but %v had some queued", queued)
+	}
+	if state == nil || len(state.Players) != 1 {
+		t.Fatalf("expected only Bob to be handed off, got %+v", state)
+	}
+	if p := state.Players[0]; p.Username != "bob" || p.Player != "Bob" || p.Room != 102 || p.Width != 100 || p.Height != 40 {
+		t.Errorf("unexpected handoff for Bob: %+v", p)
+	}
+	closeFD(state.Players[0].FD)
+	if _, err := os.Stat(filepath.Join(dir, "copyover.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the reboot state to be removed after the failure, got %v", err)
+	}
+
+	// Alice was told to log in again, so the failed reboot disconnects Alice.
+	alice.expect(t, "Your connection can't survive the reboot.")
+	alice.expectClosed(t)
+	// Bob was never told anything, so Bob carries on.
+	bobConn.Write([]byte("say still here\n"))
+	bob.expect(t, "Bob: still here")
+}
+
+// the directions, genders, and socials are global, so they are only loaded
+// once for all the tests.
+var (
+	globalsOnce sync.Once
+	globalsErr  error
+)
+
+func initGlobals() error {
+	globalsOnce.Do(func() {
+		var cfg struct {
+			Direction []game.Direction
+			Gender    []game.Gender
+		}
+		if _, err := toml.DecodeFile("../data/mud.toml", &cfg); err != nil {
+			globalsErr = err
+			return
+		}
+		game.InitDirs(cfg.Direction)
+		game.InitGenders(cfg.Gender)
+		globalsErr = social.Initialize(fixtureDir)
+	})
+	return globalsErr
+}
+
+// newTestWorld starts a world from the fixture, with a database in dir that has
+// an account for each of the players, named after the player in lowercase.
+func newTestWorld(t *testing.T, dir string, players ...string) (*world.World, *auth.Service, *db.Store, func()) {
+	t.Helper()
+	if err := initGlobals(); err != nil {
+		t.Fatal(err)
+	}
+	var cmds world.Commands
+	if _, err := toml.DecodeFile("../data/commands.toml", &cmds); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := db.Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range players {
+		username := strings.ToLower(name)
+		if err := st.CreateUser(&db.User{Username: username, Flags: big.NewInt(0)}, hash); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.CreatePlayer(username, &db.Player{Name: name, Gender: game.Genders[0], Flags: big.NewInt(0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wc := world.Config{
+		StartRoom: 100,
+		Commands:  cmds,
+		ChatMode:  world.ChatMode{Mode: world.ChatModeAllow, Prefix: "/"},
+		Input:     world.Input{QueueSize: 20},
+		Calendar:  game.DefaultCalendar,
+	}
+	wc.Calendar.HourLength = 1000 * time.Hour
+	shutdown := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wld, err := world.Init(wc, fixtureDir, shutdown, wg)
+	if err != nil {
+		st.Close()
+		t.Fatal(err)
+	}
+	svc, err := auth.New(auth.Config{BcryptCost: bcrypt.MinCost, Active: wld.Active, Sent: wld.Sent()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wld, svc, st, func() {
+		close(shutdown)
+		wg.Wait()
+		st.Close()
+	}
+}
+
+// dialFD connects to a new TCP listener, and returns the client's end and a
+// duplicate of the server end's file descriptor, as a reboot hands it over.
+func dialFD(t *testing.T) (net.Conn, uintptr) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	fd, err := dupFD(server.(*net.TCPConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, fd
+}
+
+// recorder keeps everything read from a connection.
+type recorder struct {
+	mu     sync.Mutex
+	buf    string
+	closed bool
+	ready  chan struct{}
+}
+
+// record reads from the connection until it's closed, sleeping between reads
+// to act like a slow client if delay isn't zero.
+func record(conn net.Conn, delay time.Duration) *recorder {
+	r := &recorder{ready: make(chan struct{}, 1)}
+	go func() {
+		b := make([]byte, 16)
+		for {
+			n, err := conn.Read(b)
+			r.mu.Lock()
+			r.buf += string(b[:n])
+			r.closed = err != nil
+			r.mu.Unlock()
+			select {
+			case r.ready <- struct{}{}:
+			default:
+			}
+			if err != nil {
+				return
+			}
+			time.Sleep(delay)
+		}
+	}()
+	return r
+}
+
+// expect waits for s to be read, and consumes the output up to it.
+func (r *recorder) expect(t *testing.T, s string) {
+	t.Helper()
+	r.wait(t, s, func() bool {
+		i := strings.Index(r.buf, s)
+		if i >= 0 {
+			r.buf = r.buf[i+len(s):]
+		}
+		return i >= 0
+	})
+}
+
+// expectClosed waits for the connection to be closed.
+func (r *recorder) expectClosed(t *testing.T) {
+	t.Helper()
+	r.wait(t, "the connection to close", func() bool { return r.closed })
+}
+
+// wait waits for done to return true.  done is called with r.mu held.
+func (r *recorder) wait(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		r.mu.Lock()
+		ok := done()
+		buf := r.buf
+		r.mu.Unlock()
+		if ok {
+			return
+		}
+		select {
+		case <-r.ready:
+		case <-timeout:
+			t.Fatalf("never saw %s, got:\n%s", what, buf)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupFD duplicates the connection's file descriptor.  Unlike the descriptors
+// the net package creates, the copy is kept open across exec.
+func dupFD(c syscall.Conn) (uintptr, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var fd int
+	var dupErr error
+	err = rc.Control(func(orig uintptr) {
+		fd, dupErr = syscall.Dup(int(orig))
+	})
+	if err != nil {
+		return 0, err
+	}
+	if dupErr != nil {
+		return 0, dupErr
+	}
+	return uintptr(fd), nil
+}
+
+// closeFD closes a file descriptor created by dupFD.
+func closeFD(fd uintptr) {
+	syscall.Close(int(fd))
+}
+
+// execSelf replaces this process with a new copy of the same program.  It only
+// returns if that fails.
+func execSelf() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/world"
+)
+
+// sessionsTimeout is how long /debug/world waits for the global worker before
+// giving up on the players' sessions.
+const sessionsTimeout = 5 * time.Second
+
+// serveDebug starts an http server on the given localhost port for diagnosing
+// a running MUD.  Every request must include the token, either as a bearer
+// token in the Authorization header or as the token query parameter.  It serves:
+//
+//	/debug/pprof/      the standard Go profiles, for go tool pprof
+//	/debug/goroutines  a stack dump of every goroutine
+//	/debug/world       a JSON snapshot of the zones and players' sessions
+func serveDebug(port int, token string, wld *world.World) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rpprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	mux.HandleFunc("/debug/world", func(w http.ResponseWriter, r *http.Request) {
+		snap := debugSnapshot{
+			Status: wld.Status(),
+			Zones:  wld.Zones(),
+		}
+		// the world is most worth looking at when it's stuck, so don't wait
+		// forever on the global worker.
+		ctx, cancel := context.WithTimeout(r.Context(), sessionsTimeout)
+		defer cancel()
+		sessions, err := wld.Sessions(ctx)
+		if err != nil {
+			snap.SessionsUnavailable = fmt.Sprintf("sessions unavailable: the global worker didn't answer: %v", err)
+		}
+		snap.Sessions = sessions
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(snap)
+	})
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	logger.Info("serving debug endpoints", "url", fmt.Sprintf("http://%v/debug/", l.Addr()))
+	go func() {
+		err := http.Serve(l, requireToken(token, mux))
+		logger.Error("debug server exited", logging.Err(err))
+	}()
+	return nil
+}
+
+// debugSnapshot is what /debug/world reports.
+type debugSnapshot struct {
+	Status   world.Status
+	Zones    []world.ZoneInfo
+	Sessions []world.SessionInfo
+
+	// SessionsUnavailable says why Sessions is missing, if it is.
+	SessionsUnavailable string `json:",omitempty"`
+}
+
+// requireToken only passes on requests that include the token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			got = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logger.Warn("rejected debug request", logging.AddrKey, r.RemoteAddr, "path", r.URL.Path)
+			http.Error(w, "a valid token is required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
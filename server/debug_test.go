@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	h := requireToken("secret", ok)
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		status int
+	}{{
+		name:   "missing token",
+		url:    "/debug/world",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "wrong query token",
+		url:    "/debug/world?token=guess",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "wrong bearer token",
+		url:    "/debug/world",
+		header: "Bearer guess",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "token without bearer",
+		url:    "/debug/world",
+		header: "secret",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "wrong bearer token overrides a good query token",
+		url:    "/debug/world?token=secret",
+		header: "Bearer guess",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "bearer token",
+		url:    "/debug/world",
+		header: "Bearer secret",
+		status: http.StatusOK,
+	}, {
+		name:   "query token",
+		url:    "/debug/world?token=secret",
+		status: http.StatusOK,
+	}}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.url, nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+			if test.status != http.StatusOK && w.Body.String() == "ok" {
+				t.Error("expected the request not to reach the handler")
+			}
+		})
+	}
+}
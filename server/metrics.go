@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/world"
+)
+
+// serveMetrics starts an http server on the given localhost port that reports
+// the MUD's metrics in the Prometheus text format at /metrics.
+func serveMetrics(port int, wld *world.World) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, wld)
+	})
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	logger.Info("serving metrics", "url", fmt.Sprintf("http://%v/metrics", l.Addr()))
+	go func() {
+		err := http.Serve(l, mux)
+		logger.Error("metrics server exited", logging.Err(err))
+	}()
+	return nil
+}
+
+// writeMetrics writes all the metrics in the Prometheus text format.
+func writeMetrics(w io.Writer, wld *world.World) {
+	stats := wld.WorkerStats()
+
+	workerMetric(w, stats, "ticks_total", "counter", "Ticks the worker has run.",
+		func(s game.WorkerStats) float64 { return float64(s.Ticks) })
+	workerMetric(w, stats, "events_total", "counter", "Events the worker has handled.",
+		func(s game.WorkerStats) float64 { return float64(s.Events) })
+	workerMetric(w, stats, "tick_events", "gauge", "Events handled in the worker's last tick.",
+		func(s game.WorkerStats) float64 { return float64(s.LastTickEvents) })
+	workerMetric(w, stats, "tick_seconds", "gauge", "Time spent working in the worker's last tick.",
+		func(s game.WorkerStats) float64 { return s.LastTick.Seconds() })
+	workerMetric(w, stats, "tick_seconds_total", "counter", "Total time the worker has spent working.",
+		func(s game.WorkerStats) float64 { return s.TotalTick.Seconds() })
+	workerMetric(w, stats, "tick_seconds_max", "gauge", "The longest time the worker has spent working in a tick.",
+		func(s game.WorkerStats) float64 { return s.MaxTick.Seconds() })
+	workerMetric(w, stats, "overruns_total", "counter", "Ticks that took longer than the tick length.",
+		func(s game.WorkerStats) float64 { return float64(s.Overruns) })
+	workerMetric(w, stats, "queue_wait_seconds_total", "counter", "Total time events waited to be handled.",
+		func(s game.WorkerStats) float64 { return s.QueueWait.Seconds() })
+	workerMetric(w, stats, "queue_wait_seconds_max", "gauge", "The longest time an event waited to be handled.",
+		func(s game.WorkerStats) float64 { return s.MaxQueueWait.Seconds() })
+	workerMetric(w, stats, "panics_total", "counter", "Events that panicked.",
+		func(s game.WorkerStats) float64 { return float64(s.Panics) })
+
+	metric(w, "players", "gauge", "Players in the world.", float64(wld.Status().Players))
+	metric(w, "commands_total", "counter", "Commands players have typed.", float64(wld.CommandsRun()))
+	metric(w, "commands_per_second", "gauge", "Commands players typed in the last second.", float64(wld.CommandRate()))
+	metric(w, "bytes_sent_total", "counter", "Bytes of output sent to players.", float64(wld.Sent().Total()))
+}
+
+// metric writes a single unlabeled metric.
+func metric(w io.Writer, name, typ, help string, val float64) {
+	name = "claymud_" + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, val)
+}
+
+// workerMetric writes a metric with a value for each worker.
+func workerMetric(w io.Writer, stats []game.WorkerStats, name, typ, help string, val func(game.WorkerStats) float64) {
+	name = "claymud_worker_" + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s{worker=%q} %v\n", name, s.Name, val(s))
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/natefinch/claymud/telnet"
+	"github.com/natefinch/claymud/world"
+)
+
+// msspVars returns a function that generates the MSSP variables for MUD
+// listing crawlers.  The name and extra fields come from the config, the rest
+// are generated from the current state of the world.
+func msspVars(wld *world.World, name string, port int, fields map[string]string) func() []telnet.Var {
+	if name == "" {
+		name = "ClayMUD"
+	}
+	// sort the extra fields so the output is stable.
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return func() []telnet.Var {
+		st := wld.Status()
+		vars := []telnet.Var{
+			{Name: "NAME", Values: []string{name}},
+			{Name: "PLAYERS", Values: []string{strconv.Itoa(st.Players)}},
+			{Name: "UPTIME", Values: []string{strconv.FormatInt(st.Started.Unix(), 10)}},
+			{Name: "CODEBASE", Values: []string{"ClayMUD " + gitTag}},
+			{Name: "AREAS", Values: []string{strconv.Itoa(st.Zones)}},
+			{Name: "ROOMS", Values: []string{strconv.Itoa(st.Rooms)}},
+			{Name: "MOBILES", Values: []string{strconv.Itoa(st.Mobs)}},
+			{Name: "PORT", Values: []string{strconv.Itoa(port)}},
+		}
+		for _, k := range keys {
+			vars = append(vars, telnet.Var{Name: k, Values: []string{fields[k]}})
+		}
+		return vars
+	}
+}
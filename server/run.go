@@ -1,11 +1,12 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"net"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"sync"
@@ -15,11 +16,15 @@ import (
 	"github.com/natefinch/claymud/db"
 	"github.com/natefinch/claymud/game"
 	"github.com/natefinch/claymud/game/social"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/server/config"
+	"github.com/natefinch/claymud/telnet"
 	"github.com/natefinch/claymud/util"
 	"github.com/natefinch/claymud/world"
 )
 
+var logger = logging.For("server")
+
 // set by ldflags when you "mage build"
 var (
 	commitHash = "<not set>"
@@ -29,10 +34,13 @@ var (
 
 // Main is the main entrypoint to the server
 func Main() error {
-	var port int
+	var port, ticks int
 	var version bool
+	var seed int64
 	flag.IntVar(&port, "port", 8888, "specifies the port the server listens on")
 	flag.BoolVar(&version, "version", false, "show version info")
+	flag.IntVar(&ticks, "simulate", 0, "run this many ticks of the world without players or listeners, then exit")
+	flag.Int64Var(&seed, "seed", 0, "seed for the world's random numbers, 0 picks one based on the time")
 	flag.Parse()
 
 	if version {
@@ -48,10 +56,11 @@ func Main() error {
 	if err != nil {
 		return err
 	}
-	log.Println("ClayMUD", gitTag)
-	log.Println("Build Date:", timestamp)
-	log.Println("Commit:", commitHash)
-	log.Println("built with:", runtime.Version())
+	logger.Info("ClayMUD "+gitTag, "built", timestamp, "commit", commitHash, "go", runtime.Version())
+
+	if ticks > 0 {
+		return simulate(cfg, ticks, seed)
+	}
 
 	dir := cfg.DataDir
 	game.InitGenders(cfg.Gender)
@@ -59,8 +68,6 @@ func Main() error {
 	if err := social.Initialize(dir); err != nil {
 		return err
 	}
-	auth.Init(cfg.MainTitle, cfg.BcryptCost)
-
 	// db must be before world!
 	st, err := db.Init(dir)
 	if err != nil {
@@ -79,12 +86,164 @@ func Main() error {
 		select {
 		case <-done:
 		case <-time.After(10 * time.Second):
-			log.Print("Timed out waiting for all goroutines to clean up.  Killing process.")
+			logger.Error("timed out waiting for all goroutines to clean up, killing process")
 		}
 	}()
+	wc, err := worldConfig(cfg)
+	if err != nil {
+		return err
+	}
+	wc.Seed = seed
+
+	// World needs to be last.
+	wld, err := world.Init(wc, dir, shutdown, wg)
+	if err != nil {
+		return err
+	}
+	svc, err := auth.New(auth.Config{
+		Title:         cfg.MainTitle,
+		BcryptCost:    cfg.BcryptCost,
+		MaxLineLength: cfg.Input.MaxLineLength,
+		OutputBuffer:  cfg.Output.BufferSize,
+		OutputStall:   cfg.Output.StallTimeout.Duration,
+		Active:        wld.Active,
+		Sent:          wld.Sent(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// this has to happen before we start listening, so we can reuse the old
+	// listeners.
+	copyover, err := loadCopyover()
+	if err != nil {
+		return err
+	}
+
+	if cfg.SSH.Port != 0 {
+		if err := serveSSH(cfg.SSH.Port, cfg.SSH.HostKeyFile, svc, st, wld); err != nil {
+			return err
+		}
+	}
+	if cfg.Web.Port != 0 {
+		if cfg.TLS.DisablePlain {
+			return errors.New("the plaintext port is disabled, but the web client is enabled and doesn't use TLS")
+		}
+		if err := serveWeb(cfg.Web.Port, svc, st, wld); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Metrics.Port != 0 {
+		if err := serveMetrics(cfg.Metrics.Port, wld); err != nil {
+			return err
+		}
+	}
+	if cfg.Debug.Port != 0 {
+		if err := serveDebug(cfg.Debug.Port, cfg.Debug.Token, wld); err != nil {
+			return err
+		}
+	}
+
+	var mssp func() []telnet.Var
+	if cfg.MSSP.Enabled {
+		fields := cfg.MSSP.Fields
+		if cfg.TLS.Port != 0 {
+			if fields == nil {
+				fields = map[string]string{}
+			}
+			if _, ok := fields["SSL"]; !ok {
+				fields["SSL"] = strconv.Itoa(cfg.TLS.Port)
+			}
+		}
+		mssp = msspVars(wld, cfg.MSSP.Name, port, fields)
+	}
+
+	errc := make(chan error, 2)
+	if cfg.TLS.Port != 0 {
+		tlsCfg, err := tlsConfig(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		l, err := listen(cfg.TLS.Port)
+		if err != nil {
+			return err
+		}
+		logger.Info("running ClayMUD with TLS", "listen", l.Addr().String())
+		go func() {
+			errc <- serve(l, tlsCfg, mssp, svc, st, wld)
+		}()
+	}
+	if !cfg.TLS.DisablePlain {
+		l, err := listen(port)
+		if err != nil {
+			return err
+		}
+		logger.Info("running ClayMUD", "listen", l.Addr().String())
+		go func() {
+			errc <- serve(l, nil, mssp, svc, st, wld)
+		}()
+	} else if cfg.TLS.Port == 0 {
+		return errors.New("the plaintext port is disabled, but there is no TLS port configured")
+	}
+	if copyover != nil {
+		restorePlayers(copyover, mssp, svc, st, wld)
+	}
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case stop := <-wld.Stops():
+			if !stop.Reboot {
+				logger.Info("shut down", logging.Player(stop.By), "reason", stop.Reason, logging.Event("shutdown"))
+				return nil
+			}
+			logger.Info("rebooted", logging.Player(stop.By), "reason", stop.Reason, logging.Event("reboot"))
+			if err := reboot(dir, wld); err != nil {
+				logger.Error("reboot failed", logging.Err(err), logging.Event("reboot"))
+				wld.Announce("The reboot failed, carry on!")
+			}
+		}
+	}
+}
+
+// worldConfig converts the MUD's config into the world's config.
+func worldConfig(cfg *config.Config) (world.Config, error) {
 	wc := world.Config{
-		Commands:  cfg.Commands,
-		StartRoom: cfg.StartRoom,
+		Commands:   cfg.Commands,
+		StartRoom:  cfg.StartRoom,
+		Attributes: cfg.Attributes,
+		Races:      cfg.Races,
+		Classes:    cfg.Classes,
+	}
+	wc.Idle = world.Idle{
+		Warn:     cfg.Idle.Warn.Duration,
+		Void:     cfg.Idle.Void.Duration,
+		Timeout:  cfg.Idle.Timeout.Duration,
+		VoidRoom: util.ID(cfg.Idle.VoidRoom),
+	}
+	wc.LinkDeadGrace = cfg.LinkDead.Grace.Duration
+	wc.Calendar = game.Calendar{
+		HourLength:   cfg.Time.HourLength.Duration,
+		HoursPerDay:  cfg.Time.HoursPerDay,
+		DaysPerMonth: cfg.Time.DaysPerMonth,
+		Sunrise:      cfg.Time.Sunrise,
+		Sunset:       cfg.Time.Sunset,
+		Months:       cfg.Time.Months,
+		Seasons:      cfg.Time.Season,
+	}
+	if len(wc.Calendar.Months) == 0 {
+		// the default seasons only make sense with the default months.
+		wc.Calendar.Months = game.DefaultCalendar.Months
+		if len(wc.Calendar.Seasons) == 0 {
+			wc.Calendar.Seasons = game.DefaultCalendar.Seasons
+		}
+	}
+	wc.Input = world.Input{
+		QueueSize:       cfg.Input.QueueSize,
+		CommandsPerTick: cfg.Input.CommandsPerTick,
+		SpamLimit:       cfg.Input.SpamLimit,
 	}
 	wc.ChatMode.Default = cfg.ChatMode.Default
 	wc.ChatMode.Prefix = cfg.ChatMode.Prefix
@@ -97,53 +256,100 @@ func Main() error {
 		wc.ChatMode.Mode = world.ChatModeRequire
 	default:
 		// we already checked this, but belt and suspenders is ok
-		return fmt.Errorf("Expected allow, deny, or require for ChatMode.Enabled, got %q", cfg.ChatMode.Enabled)
-	}
-
-	lock := &sync.RWMutex{}
-
-	// World needs to be last.
-	if err := world.Init(wc, dir, lock.RLocker(), shutdown, wg); err != nil {
-		return err
-	}
-	if err := world.SetStart(util.ID(cfg.StartRoom)); err != nil {
-		return err
-	}
-	if err := world.InitActions(filepath.Join(dir, "scripts")); err != nil {
-		return err
-	}
-	global := game.SpawnWorker(lock, shutdown, wg)
-
-	host := net.JoinHostPort("", strconv.Itoa(port))
-	log.Printf("Running ClayMUD on %v", host)
-
-	addr, err := net.ResolveTCPAddr("tcp", host)
-	if err != nil {
-		return err
+		return wc, fmt.Errorf("Expected allow, deny, or require for ChatMode.Enabled, got %q", cfg.ChatMode.Enabled)
 	}
+	return wc, nil
+}
 
-	listener, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		return err
-	}
+// serve accepts telnet connections from the listener until it is closed.  If
+// tlsCfg is not nil, connections are wrapped in TLS.
+func serve(l *net.TCPListener, tlsCfg *tls.Config, mssp func() []telnet.Var, svc *auth.Service, st *db.Store, wld *world.World) error {
 	for {
-		conn, err := listener.AcceptTCP()
+		conn, err := l.AcceptTCP()
+		if errors.Is(err, net.ErrClosed) {
+			return err
+		}
 		if err != nil {
-			log.Printf("Error accepting TCP connection: %v", err)
+			logger.Error("error accepting TCP connection", logging.Err(err))
 			continue
 		}
 		conn.SetKeepAlive(false)
 		conn.SetLinger(0)
 
 		go func() {
-			log.Printf("New connection from %v", conn.RemoteAddr())
-			user, err := auth.Login(st, conn, conn.RemoteAddr())
-			if err != nil {
-				log.Printf("error logging in user: ")
+			var c net.Conn = conn
+			if tlsCfg != nil {
+				logger.Info("new TLS connection", logging.Addr(conn.RemoteAddr()), logging.Event("connect"))
+				tc, err := tlsHandshake(conn, tlsCfg)
+				if err != nil {
+					logger.Info("TLS handshake failed", logging.Addr(conn.RemoteAddr()), logging.Err(err))
+					conn.Close()
+					return
+				}
+				c = tc
+			} else {
+				logger.Info("new connection", logging.Addr(conn.RemoteAddr()), logging.Event("connect"))
 			}
-			if err := world.SpawnPlayer(st, user, global); err != nil {
-				log.Printf("error during spawn player for user %s: %s", user.Username, err)
+			tc := telnet.NewConn(c)
+			tc.MSSP = mssp
+			if err := tc.Offer(); err != nil {
+				logger.Warn("error negotiating telnet options", logging.Addr(conn.RemoteAddr()), logging.Err(err))
+				c.Close()
+				return
 			}
+			session(svc, st, tc, conn.RemoteAddr(), wld)
 		}()
 	}
 }
+
+// session runs a new connection through login and then into the world.  It
+// returns when the player leaves the world.
+func session(svc *auth.Service, st *db.Store, rwc io.ReadWriteCloser, addr net.Addr, wld *world.World) {
+	if !allowed(st, rwc, addr) {
+		return
+	}
+	user, err := svc.Login(st, rwc, addr)
+	if err != nil {
+		logger.Info("login failed", logging.Addr(addr), logging.Err(err), logging.Event("login"))
+		rwc.Close()
+		return
+	}
+	spawn(st, user, wld)
+}
+
+// allowed checks the address against the ban list.  If the address is banned,
+// allowed tells the user, closes the connection and returns false.
+func allowed(st *db.Store, rwc io.ReadWriteCloser, addr net.Addr) bool {
+	ban, err := auth.FindBan(st, addr)
+	if err != nil {
+		logger.Error("rejected connection, error checking bans", logging.Addr(addr), logging.Err(err), logging.Event("ban"))
+		rwc.Close()
+		return false
+	}
+	if ban == nil {
+		return true
+	}
+	switch ban.Level {
+	case db.BanAll:
+		logger.Info("rejected connection from banned site", logging.Addr(addr), "ban", ban.Net, logging.Event("ban"))
+		io.WriteString(rwc, "Connections from your site are not allowed.\n")
+		if ban.Reason != "" {
+			io.WriteString(rwc, "Reason: "+ban.Reason+"\n")
+		}
+		rwc.Close()
+		return false
+	case db.BanNotice:
+		if ban.Reason != "" {
+			io.WriteString(rwc, "NOTICE: "+ban.Reason+"\n")
+		}
+	}
+	return true
+}
+
+// spawn puts the logged in user into the world.  It returns when the player
+// leaves the world.
+func spawn(st *db.Store, user *auth.User, wld *world.World) {
+	if err := wld.SpawnPlayer(st, user); err != nil {
+		logger.Error("error during spawn player", logging.User(user.Username), logging.Err(err))
+	}
+}
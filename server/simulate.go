@@ -0,0 +1,58 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/game/social"
+	"github.com/natefinch/claymud/server/config"
+	"github.com/natefinch/claymud/world"
+)
+
+// simEpoch is the game time a simulation starts at, so that every run with the
+// same seed sees the same times.
+var simEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// simulate loads the world and runs it for the given number of ticks as fast as
+// possible, without any players or listeners, then reports how it went.
+func simulate(cfg *config.Config, ticks int, seed int64) error {
+	dir := cfg.DataDir
+	game.InitGenders(cfg.Gender)
+	game.InitDirs(cfg.Direction)
+	if err := social.Initialize(dir); err != nil {
+		return err
+	}
+	wc, err := worldConfig(cfg)
+	if err != nil {
+		return err
+	}
+	clock := game.NewManualClock(simEpoch)
+	wc.Clock = clock
+	wc.Seed = seed
+	wc.Manual = true
+
+	// manual workers never use these, but the world wants them.
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	wld, err := world.Init(wc, dir, shutdown, &sync.WaitGroup{})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("simulating", "ticks", ticks, "seed", seed)
+	start := time.Now()
+	wld.Simulate(clock, ticks)
+	took := time.Since(start)
+
+	var events int64
+	for _, s := range wld.WorkerStats() {
+		events += s.Events
+	}
+	logger.Info("simulation done",
+		"gameTime", clock.Now().Sub(simEpoch),
+		"took", took.Round(time.Millisecond),
+		"ticksPerSec", int64(float64(ticks)/took.Seconds()),
+		"events", events)
+	return nil
+}
@@ -0,0 +1,390 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/world"
+)
+
+// userExt is the key in the ssh permissions extensions where we store the name
+// of a user that was authenticated by the ssh handshake.
+const userExt = "claymud-user"
+
+// serveSSH starts listening for ssh connections on the given port.
+func serveSSH(port int, hostKeyFile string, svc *auth.Service, st *db.Store, wld *world.World) error {
+	signer, err := hostKey(hostKeyFile)
+	if err != nil {
+		return err
+	}
+	cfg := sshConfig(svc, st, signer)
+
+	l, err := listen(port)
+	if err != nil {
+		return err
+	}
+	logger.Info("running ClayMUD ssh server", "listen", l.Addr().String())
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				logger.Error("ssh server exited", logging.Err(err))
+				return
+			}
+			go handleSSH(conn, cfg, svc, st, wld)
+		}
+	}()
+	return nil
+}
+
+// sshConfig returns the ssh server config, which authenticates users against
+// the store.
+func sshConfig(svc *auth.Service, st *db.Store, signer ssh.Signer) *ssh.ServerConfig {
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if err := checkSSHBan(st, conn.RemoteAddr()); err != nil {
+				return nil, err
+			}
+			ok, err := auth.CheckKey(st, conn.User(), key)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown public key for %q", conn.User())
+			}
+			return &ssh.Permissions{Extensions: map[string]string{userExt: conn.User()}}, nil
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if err := checkSSHBan(st, conn.RemoteAddr()); err != nil {
+				return nil, err
+			}
+			if err := svc.CheckPassword(st, conn.User(), string(pass), conn.RemoteAddr()); err != nil {
+				logger.Info("failed ssh password login", logging.User(conn.User()), logging.Addr(conn.RemoteAddr()), logging.Event("login"))
+				return nil, err
+			}
+			return &ssh.Permissions{Extensions: map[string]string{userExt: conn.User()}}, nil
+		},
+		// People without a key get dropped into the normal login flow, where
+		// they can also create a new account.
+		KeyboardInteractiveCallback: func(ssh.ConnMetadata, ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			return &ssh.Permissions{}, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+	return cfg
+}
+
+// checkSSHBan returns an error if the address is banned from connecting at all,
+// so banned sites can't try passwords or keys.
+func checkSSHBan(st *db.Store, addr net.Addr) error {
+	ban, err := auth.FindBan(st, addr)
+	if err != nil {
+		return err
+	}
+	if ban != nil && ban.Level == db.BanAll {
+		return fmt.Errorf("connections from %s are banned", ban.Net)
+	}
+	return nil
+}
+
+// hostKey loads the ssh host key from the file, creating a new key if the file
+// does not exist.
+func hostKey(filename string) (ssh.Signer, error) {
+	b, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		logger.Info("generating new ssh host key", "file", filename)
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		block, err := ssh.MarshalPrivateKey(key, "claymud host key")
+		if err != nil {
+			return nil, err
+		}
+		b = pem.EncodeToMemory(block)
+		if err := os.WriteFile(filename, b, 0600); err != nil {
+			return nil, fmt.Errorf("can't write ssh host key: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read ssh host key: %v", err)
+	}
+	return ssh.ParsePrivateKey(b)
+}
+
+// handleSSH runs the ssh handshake and starts a session on the first shell
+// request.
+func handleSSH(conn net.Conn, cfg *ssh.ServerConfig, svc *auth.Service, st *db.Store, wld *world.World) {
+	// check bans before the handshake, so banned sites never get to try a
+	// password.
+	if err := checkSSHBan(st, conn.RemoteAddr()); err != nil {
+		logger.Info("rejected ssh connection", logging.Addr(conn.RemoteAddr()), logging.Err(err), logging.Event("ban"))
+		conn.Close()
+		return
+	}
+	sc, chans, reqs, err := sshHandshake(conn, cfg)
+	if err != nil {
+		logger.Info("ssh handshake failed", logging.Addr(conn.RemoteAddr()), logging.Err(err))
+		conn.Close()
+		return
+	}
+	logger.Info("new ssh connection", logging.Addr(sc.RemoteAddr()), logging.Event("connect"))
+	go ssh.DiscardRequests(reqs)
+
+	started := false
+	for nc := range chans {
+		if nc.ChannelType() != "session" || started {
+			nc.Reject(ssh.Prohibited, "only one interactive session is allowed")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			logger.Warn("error accepting ssh channel", logging.Addr(sc.RemoteAddr()), logging.Err(err))
+			continue
+		}
+		started = true
+		term := &sshTerm{Channel: ch}
+		go term.handleRequests(reqs, func() {
+			defer sc.Close()
+			username := sc.Permissions.Extensions[userExt]
+			if username == "" {
+				session(svc, st, term, sc.RemoteAddr(), wld)
+				return
+			}
+			if !allowed(st, term, sc.RemoteAddr()) {
+				return
+			}
+			user, err := svc.LoginVerified(st, term, sc.RemoteAddr(), username)
+			if err != nil {
+				logger.Info("ssh login failed", logging.User(username), logging.Addr(sc.RemoteAddr()), logging.Err(err), logging.Event("login"))
+				return
+			}
+			spawn(st, user, wld)
+		})
+	}
+}
+
+// sshHandshake runs the ssh handshake, including authentication, giving up
+// after handshakeTimeout.
+func sshHandshake(conn net.Conn, cfg *ssh.ServerConfig) (*ssh.ServerConn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, nil, nil, err
+	}
+	sc, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		sc.Close()
+		return nil, nil, nil, err
+	}
+	return sc, chans, reqs, nil
+}
+
+// sshTerm adapts an ssh channel to the line based input and output the rest of
+// the MUD expects.  When the client has requested a pty, the client's terminal
+// is in raw mode, so sshTerm echoes input, handles backspace, and translates
+// newlines.
+type sshTerm struct {
+	ssh.Channel
+
+	mu      sync.Mutex
+	pty     bool
+	line    []byte // line being edited
+	ready   []byte // completed lines waiting to be read
+	noEcho  bool   // don't echo input, e.g. for passwords
+	sawCR   bool   // the last key was \r
+	esc     bool   // in the middle of an escape sequence
+	width   int32
+	height  int32
+	rawRead []byte
+}
+
+// handleRequests answers the requests on the session channel.  It calls start
+// in a new goroutine when the client asks for a shell.
+func (t *sshTerm) handleRequests(reqs <-chan *ssh.Request, start func()) {
+	started := false
+	for req := range reqs {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			var pty ptyRequest
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				break
+			}
+			t.setSize(pty.Width, pty.Height)
+			t.mu.Lock()
+			t.pty = true
+			t.mu.Unlock()
+			ok = true
+		case "window-change":
+			var wc windowChange
+			if err := ssh.Unmarshal(req.Payload, &wc); err != nil {
+				break
+			}
+			t.setSize(wc.Width, wc.Height)
+			ok = true
+		case "shell":
+			ok = !started
+			if ok {
+				started = true
+				go start()
+			}
+		case "env":
+			ok = true
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+// ptyRequest is the payload of a pty-req request, from RFC 4254 section 6.2.
+type ptyRequest struct {
+	Term          string
+	Width, Height uint32
+	PxW, PxH      uint32
+	Modes         string
+}
+
+// windowChange is the payload of a window-change request, from RFC 4254
+// section 6.7.
+type windowChange struct {
+	Width, Height uint32
+	PxW, PxH      uint32
+}
+
+// setSize sets the window size in characters.
+func (t *sshTerm) setSize(width, height uint32) {
+	atomic.StoreInt32(&t.width, int32(width))
+	atomic.StoreInt32(&t.height, int32(height))
+}
+
+// Size implements util.Sizer.
+func (t *sshTerm) Size() (width, height int) {
+	return int(atomic.LoadInt32(&t.width)), int(atomic.LoadInt32(&t.height))
+}
+
+// Read implements io.Reader.
+func (t *sshTerm) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	pty := t.pty
+	t.mu.Unlock()
+	if !pty {
+		return t.Channel.Read(p)
+	}
+	if t.rawRead == nil {
+		t.rawRead = make([]byte, 256)
+	}
+	for len(t.ready) == 0 {
+		n, err := t.Channel.Read(t.rawRead)
+		if err != nil {
+			return 0, err
+		}
+		if err := t.edit(t.rawRead[:n]); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, t.ready)
+	t.ready = t.ready[n:]
+	return n, nil
+}
+
+// edit applies the keys the user typed to the current line, echoing them back.
+func (t *sshTerm) edit(keys []byte) error {
+	t.mu.Lock()
+	noEcho := t.noEcho
+	t.mu.Unlock()
+	var echo []byte
+	for _, k := range keys {
+		if t.esc {
+			// skip escape sequences like arrow keys, which end with a letter.
+			if k != '[' && k != 'O' && k >= 0x40 && k <= 0x7e {
+				t.esc = false
+			}
+			continue
+		}
+		switch k {
+		case 27:
+			t.sawCR = false
+			t.esc = true
+		case '\r', '\n':
+			if k == '\n' && t.sawCR {
+				// \r\n, we already handled the \r
+				t.sawCR = false
+				continue
+			}
+			t.sawCR = k == '\r'
+			t.ready = append(t.ready, t.line...)
+			t.ready = append(t.ready, '\n')
+			t.line = t.line[:0]
+			echo = append(echo, '\r', '\n')
+		case 127, 8: // delete and backspace
+			t.sawCR = false
+			if len(t.line) > 0 {
+				t.line = t.line[:len(t.line)-1]
+				if !noEcho {
+					echo = append(echo, '\b', ' ', '\b')
+				}
+			}
+		case 3, 4: // ctrl-c, ctrl-d
+			t.Channel.Write([]byte("\r\n"))
+			return io.EOF
+		default:
+			t.sawCR = false
+			if k < 32 {
+				// ignore other control characters.
+				continue
+			}
+			t.line = append(t.line, k)
+			if !noEcho {
+				echo = append(echo, k)
+			}
+		}
+	}
+	if len(echo) > 0 {
+		_, err := t.Channel.Write(echo)
+		return err
+	}
+	return nil
+}
+
+// Write implements io.Writer.  When a pty was requested, newlines are
+// translated to \r\n.
+func (t *sshTerm) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	pty := t.pty
+	// the MUD doesn't tell us when it's asking for a password, so guess from
+	// the prompt.
+	if len(p) > 0 {
+		t.noEcho = strings.HasSuffix(strings.ToLower(string(p)), "password: ")
+	}
+	t.mu.Unlock()
+	if !pty {
+		return t.Channel.Write(p)
+	}
+	out := make([]byte, 0, len(p)+8)
+	for _, b := range p {
+		if b == '\n' {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+	}
+	if _, err := t.Channel.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
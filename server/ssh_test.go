@@ -0,0 +1,219 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+)
+
+func TestSSHBannedSiteCantTryPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := db.Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CreateUser(&db.User{Username: "bob", Flags: big.NewInt(0)}, hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveBan(db.Ban{Net: "127.0.0.1/32", Level: db.BanAll}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := auth.New(auth.Config{BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := sshConfig(svc, st, signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		handleSSH(conn, cfg, svc, st, nil)
+	}()
+
+	_, err = ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "bob",
+		Auth:            []ssh.AuthMethod{ssh.Password("password")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err == nil {
+		t.Fatal("expected a banned site to be refused")
+	}
+
+	// the callbacks refuse banned sites too, in case they're ever reached.
+	meta := fakeConnMeta{user: "bob", addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}}
+	if _, err := cfg.PasswordCallback(meta, []byte("password")); err == nil {
+		t.Error("expected the password callback to refuse a banned site")
+	}
+
+	// a successful password check records the login, so an empty last login
+	// means the password was never checked.
+	u, err := st.FindUser("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.LastLogin.IsZero() {
+		t.Errorf("expected the banned site's password never to be checked, but bob logged in at %v", u.LastLogin)
+	}
+
+	meta.addr = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}
+	if _, err := cfg.PasswordCallback(meta, []byte("password")); err != nil {
+		t.Errorf("expected an unbanned site to log in, got %v", err)
+	}
+}
+
+func TestSSHHandshakeTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := db.Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := auth.New(auth.Config{BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := sshConfig(svc, st, signer)
+
+	old := handshakeTimeout
+	handshakeTimeout = 50 * time.Millisecond
+	defer func() { handshakeTimeout = old }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		handleSSH(conn, cfg, svc, st, nil)
+	}()
+
+	// a client that connects and never says anything.
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the handshake to time out")
+	}
+	// the server hung up, so reading ends once its version line is read.
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(c); err != nil {
+		t.Errorf("expected the server to close the connection, got %v", err)
+	}
+}
+
+func TestSSHPtyRequest(t *testing.T) {
+	// a string length that wraps around when 12 is added to it.
+	huge := []byte{0xff, 0xff, 0xff, 0xf9, 0, 0, 0, 0, 0, 0, 0, 0}
+	tests := []struct {
+		name    string
+		payload []byte
+		pty     bool
+		width   int
+		height  int
+	}{{
+		name:    "valid",
+		payload: ssh.Marshal(ptyRequest{Term: "xterm", Width: 100, Height: 40}),
+		pty:     true,
+		width:   100,
+		height:  40,
+	}, {
+		name:    "string length overflows",
+		payload: huge,
+	}, {
+		name:    "truncated",
+		payload: ssh.Marshal(ptyRequest{Term: "xterm", Width: 100, Height: 40})[:12],
+	}, {
+		name: "empty",
+	}}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			term := &sshTerm{}
+			reqs := make(chan *ssh.Request, 1)
+			reqs <- &ssh.Request{Type: "pty-req", Payload: test.payload}
+			close(reqs)
+			// a panic here takes down the test, just as it would the MUD.
+			term.handleRequests(reqs, func() {})
+			if term.pty != test.pty {
+				t.Errorf("expected pty to be %v, got %v", test.pty, term.pty)
+			}
+			if w, h := term.Size(); w != test.width || h != test.height {
+				t.Errorf("expected a size of %dx%d, got %dx%d", test.width, test.height, w, h)
+			}
+		})
+	}
+}
+
+// fakeConnMeta is the ssh connection metadata for a client at addr.
+type fakeConnMeta struct {
+	ssh.ConnMetadata
+	user string
+	addr net.Addr
+}
+
+func (m fakeConnMeta) User() string          { return m.user }
+func (m fakeConnMeta) RemoteAddr() net.Addr  { return m.addr }
+func (m fakeConnMeta) LocalAddr() net.Addr   { return m.addr }
+func (m fakeConnMeta) SessionID() []byte     { return nil }
+func (m fakeConnMeta) ClientVersion() []byte { return nil }
+func (m fakeConnMeta) ServerVersion() []byte { return nil }
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/natefinch/claymud/logging"
+)
+
+// handshakeTimeout is how long a client has to finish the TLS or ssh handshake
+// before it's disconnected.  It's a variable so tests can shorten it.
+var handshakeTimeout = 10 * time.Second
+
+// tlsHandshake wraps the connection in TLS and performs the handshake, giving
+// up after handshakeTimeout.
+func tlsHandshake(conn net.Conn, cfg *tls.Config) (*tls.Conn, error) {
+	c := tls.Server(conn, cfg)
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, err
+	}
+	if err := c.Handshake(); err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// tlsConfig returns a TLS config that serves the certificate in the given
+// files.  The files are checked for changes when clients connect, so a renewed
+// certificate is picked up without restarting the MUD.
+func tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		GetCertificate: r.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}, nil
+}
+
+// certReloader holds a TLS certificate and reloads it when the files on disk
+// change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mod, err := r.lastMod()
+	if err != nil {
+		logger.Warn("can't check TLS certificate for changes, using the old one", logging.Err(err))
+		return r.cert, nil
+	}
+	if mod.After(r.modTime) {
+		if err := r.loadLocked(); err != nil {
+			// Keep serving the old certificate, it's better than failing every
+			// connection because of a half-written file.  Don't try again
+			// until the files change again, so a broken file isn't read (and
+			// logged) on every connection.
+			r.modTime = mod
+			logger.Error("error reloading TLS certificate, using the old one", logging.Err(err))
+		}
+	}
+	return r.cert, nil
+}
+
+// load reads the certificate and key from disk.
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadLocked()
+}
+
+func (r *certReloader) loadLocked() error {
+	mod, err := r.lastMod()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("can't load TLS certificate: %v", err)
+	}
+	r.cert = &cert
+	r.modTime = mod
+	logger.Info("loaded TLS certificate", "file", r.certFile)
+	return nil
+}
+
+// lastMod returns the most recent modification time of the cert and key files.
+func (r *certReloader) lastMod() (time.Time, error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ki.ModTime().After(ci.ModTime()) {
+		return ki.ModTime(), nil
+	}
+	return ci.ModTime(), nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/world"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// serveWeb starts an http server on the given port that serves the browser
+// client and accepts websocket connections from it.
+func serveWeb(port int, svc *auth.Service, st *db.Store, wld *world.World) error {
+	h, err := webHandler(svc, st, wld)
+	if err != nil {
+		return err
+	}
+	l, err := listen(port)
+	if err != nil {
+		return err
+	}
+	logger.Info("running web client", "listen", l.Addr().String())
+	go func() {
+		err := http.Serve(l, h)
+		logger.Error("web client server exited", logging.Err(err))
+	}()
+	return nil
+}
+
+// webHandler serves the browser client, and starts a session for each websocket
+// it opens.
+func webHandler(svc *auth.Service, st *db.Store, wld *world.World) (http.Handler, error) {
+	static, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.Handle("/ws", websocket.Server{
+		Handshake: checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			// ws.RemoteAddr is the origin of the websocket, not the address of
+			// the client, so we have to get that from the request.
+			addr, err := net.ResolveTCPAddr("tcp", ws.Request().RemoteAddr)
+			if err != nil {
+				logger.Warn("can't parse websocket remote address", "remote", ws.Request().RemoteAddr, logging.Err(err))
+				ws.Close()
+				return
+			}
+			logger.Info("new web connection", logging.Addr(addr), logging.Event("connect"))
+			session(svc, st, ws, addr, wld)
+		},
+	})
+	return mux, nil
+}
+
+// checkOrigin only accepts websockets opened by the client we serve.  Browsers
+// send the origin of the page that opens a websocket, so this keeps other sites
+// from opening a session from a player's browser.
+func checkOrigin(cfg *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(cfg, req)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		return errors.New("missing origin")
+	}
+	if !strings.EqualFold(origin.Host, req.Host) {
+		logger.Warn("rejected websocket from another site", "origin", origin.String(), "host", req.Host, logging.Event("connect"))
+		return fmt.Errorf("origin %q doesn't match host %q", origin, req.Host)
+	}
+	cfg.Origin = origin
+	return nil
+}
@@ -0,0 +1,109 @@
+// client.js is the browser side of the ClayMUD web client.  It sends each line
+// typed by the player over a websocket and renders the ANSI color codes sent by
+// the MUD as HTML.
+(function() {
+	"use strict";
+
+	var output = document.getElementById("output");
+	var entry = document.getElementById("entry");
+	var input = document.getElementById("input");
+
+	var history = [];
+	var historyPos = 0;
+
+	// the current ANSI style, which carries over between messages.
+	var style = {bold: false, fg: 0};
+
+	var proto = location.protocol === "https:" ? "wss://" : "ws://";
+	var ws = new WebSocket(proto + location.host + "/ws");
+
+	ws.onmessage = function(ev) {
+		render(ev.data);
+		// hide what the user types when the MUD asks for a password.
+		input.type = /password: ?$/i.test(ev.data) ? "password" : "text";
+	};
+	ws.onclose = function() {
+		render("\n\x1b[31m*** Connection closed ***\x1b[0m\n");
+		input.disabled = true;
+	};
+
+	entry.onsubmit = function(ev) {
+		ev.preventDefault();
+		var line = input.value;
+		if (input.type === "text") {
+			if (line !== "") {
+				history.push(line);
+			}
+			render(line + "\n");
+		} else {
+			render("\n");
+		}
+		historyPos = history.length;
+		input.value = "";
+		ws.send(line + "\n");
+	};
+
+	input.onkeydown = function(ev) {
+		if (input.type !== "text" || history.length === 0) {
+			return;
+		}
+		if (ev.key === "ArrowUp") {
+			historyPos = Math.max(0, historyPos - 1);
+		} else if (ev.key === "ArrowDown") {
+			historyPos = Math.min(history.length, historyPos + 1);
+		} else {
+			return;
+		}
+		ev.preventDefault();
+		input.value = history[historyPos] || "";
+	};
+
+	// render appends text to the output, converting ANSI SGR codes into styled
+	// spans.
+	function render(text) {
+		var parts = text.split(/\x1b\[([0-9;]*)m/);
+		for (var i = 0; i < parts.length; i++) {
+			if (i % 2 === 1) {
+				applyCodes(parts[i]);
+				continue;
+			}
+			if (parts[i] === "") {
+				continue;
+			}
+			var span = document.createElement("span");
+			span.textContent = parts[i];
+			if (style.bold) {
+				span.classList.add("bold");
+			}
+			if (style.fg) {
+				span.classList.add("fg" + style.fg);
+			}
+			output.appendChild(span);
+		}
+		output.scrollTop = output.scrollHeight;
+	}
+
+	function applyCodes(codes) {
+		var list = codes === "" ? ["0"] : codes.split(";");
+		for (var i = 0; i < list.length; i++) {
+			var c = parseInt(list[i], 10);
+			if (c === 0) {
+				style = {bold: false, fg: 0};
+			} else if (c === 1) {
+				style.bold = true;
+			} else if (c === 22) {
+				style.bold = false;
+			} else if (c >= 30 && c <= 37) {
+				style.fg = c;
+			} else if (c === 39) {
+				style.fg = 0;
+			}
+		}
+	}
+
+	document.body.onclick = function() {
+		if (window.getSelection().toString() === "") {
+			input.focus();
+		}
+	};
+})();
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/websocket"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+)
+
+func TestWebClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := db.Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	svc, err := auth.New(auth.Config{BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := webHandler(svc, st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(b), "<title>ClayMUD</title>") {
+		t.Errorf("expected the client's page, got %d:\n%s", resp.StatusCode, b)
+	}
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	// the page we serve opens the websocket, so the origin is our own.
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatalf("expected a websocket from the client's page to connect, got %v", err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got string
+	buf := make([]byte, 1024)
+	for !strings.Contains(got, "Username: ") {
+		n, err := ws.Read(buf)
+		if err != nil {
+			t.Fatalf("never got the login prompt, got %q: %v", got, err)
+		}
+		got += string(buf[:n])
+	}
+
+	// a page on another site can't open a session from the player's browser.
+	// a different port is a different site too.
+	for _, origin := range []string{"http://evil.example", "http://127.0.0.1"} {
+		if ws, err := websocket.Dial(url, "", origin); err == nil {
+			ws.Close()
+			t.Errorf("expected a websocket from %s to be rejected", origin)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package telnet handles the telnet protocol for incoming connections.  It
+// strips telnet commands out of the input stream and answers the option
+// negotiations that ClayMUD supports.
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+)
+
+// Telnet command bytes.
+const (
+	SE   byte = 240 // end of subnegotiation
+	NOP  byte = 241 // no operation
+	GA   byte = 249 // go ahead
+	SB   byte = 250 // start of subnegotiation
+	WILL byte = 251
+	WONT byte = 252
+	DO   byte = 253
+	DONT byte = 254
+	IAC  byte = 255 // interpret as command
+)
+
+// Telnet options that ClayMUD understands.
+const (
+	OptNAWS byte = 31 // Negotiate About Window Size
+	OptMSSP byte = 70 // Mud Server Status Protocol
+)
+
+// MSSP subnegotiation bytes.
+const (
+	msspVar byte = 1
+	msspVal byte = 2
+)
+
+// parser states
+const (
+	stateData = iota
+	stateIAC
+	stateOpt
+	stateSB
+	stateSBIAC
+)
+
+// Var is a single MSSP variable.  A variable may have more than one value.
+type Var struct {
+	Name   string
+	Values []string
+}
+
+// Conn is a net.Conn that understands the telnet protocol.  Reads from a Conn
+// return only the data the user typed, with all telnet commands removed.
+type Conn struct {
+	net.Conn
+
+	// MSSP, if not nil, is called to get the server status when a client
+	// requests it.
+	MSSP func() []Var
+
+	buf   []byte
+	state int
+	cmd   byte
+	sb    []byte
+
+	// width and height are set by the client with NAWS, and read from other
+	// goroutines.
+	width, height int32
+}
+
+// NewConn wraps the connection in a telnet Conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn, buf: make([]byte, 1024)}
+}
+
+// Offer starts negotiating the options the server supports.  It asks the
+// client for its window size, and tells the client that the server supports
+// MSSP if the connection has MSSP configured.
+func (c *Conn) Offer() error {
+	b := []byte{IAC, DO, OptNAWS}
+	if c.MSSP != nil {
+		b = append(b, IAC, WILL, OptMSSP)
+	}
+	_, err := c.Conn.Write(b)
+	return err
+}
+
+// Size implements util.Sizer.  It returns the size of the client's window, or
+// zeroes if the client hasn't told us.
+func (c *Conn) Size() (width, height int) {
+	return int(atomic.LoadInt32(&c.width)), int(atomic.LoadInt32(&c.height))
+}
+
+// SetSize sets the size of the client's window, for when the size was
+// negotiated on an earlier connection to the same client.
+func (c *Conn) SetSize(width, height int) {
+	atomic.StoreInt32(&c.width, int32(width))
+	atomic.StoreInt32(&c.height, int32(height))
+}
+
+// Read implements io.Reader.  It reads from the underlying connection, removes
+// telnet commands, and handles any negotiation the client requested.
+func (c *Conn) Read(p []byte) (int, error) {
+	for {
+		max := len(p)
+		if max > len(c.buf) {
+			max = len(c.buf)
+		}
+		n, err := c.Conn.Read(c.buf[:max])
+		out, werr := c.parse(c.buf[:n], p)
+		if werr != nil {
+			return out, werr
+		}
+		// only return with no data if there's an error, otherwise the caller
+		// might think the stream is stuck.
+		if out > 0 || err != nil {
+			return out, err
+		}
+	}
+}
+
+// parse runs the telnet state machine over in, copying user data into out.  It
+// returns the number of bytes copied.
+func (c *Conn) parse(in, out []byte) (int, error) {
+	n := 0
+	for _, b := range in {
+		switch c.state {
+		case stateData:
+			if b == IAC {
+				c.state = stateIAC
+				continue
+			}
+			out[n] = b
+			n++
+		case stateIAC:
+			switch b {
+			case IAC:
+				// escaped 255
+				out[n] = b
+				n++
+				c.state = stateData
+			case WILL, WONT, DO, DONT:
+				c.cmd = b
+				c.state = stateOpt
+			case SB:
+				c.sb = c.sb[:0]
+				c.state = stateSB
+			default:
+				// GA, NOP, etc, we just ignore.
+				c.state = stateData
+			}
+		case stateOpt:
+			c.state = stateData
+			if err := c.negotiate(c.cmd, b); err != nil {
+				return n, err
+			}
+		case stateSB:
+			if b == IAC {
+				c.state = stateSBIAC
+				continue
+			}
+			c.sb = append(c.sb, b)
+		case stateSBIAC:
+			switch b {
+			case SE:
+				c.state = stateData
+				c.subnegotiate(c.sb)
+			case IAC:
+				c.sb = append(c.sb, b)
+				c.state = stateSB
+			default:
+				// malformed, drop the subnegotiation.
+				c.state = stateData
+			}
+		}
+	}
+	return n, nil
+}
+
+// negotiate responds to the client's request to enable or disable an option.
+func (c *Conn) negotiate(cmd, opt byte) error {
+	switch cmd {
+	case DO:
+		if opt == OptMSSP && c.MSSP != nil {
+			return c.sendMSSP()
+		}
+		// we don't do anything else, so refuse.
+		_, err := c.Conn.Write([]byte{IAC, WONT, opt})
+		return err
+	case WILL:
+		if opt == OptNAWS {
+			// this is the answer to our DO in Offer, so we don't respond.
+			return nil
+		}
+		_, err := c.Conn.Write([]byte{IAC, DONT, opt})
+		return err
+	}
+	// WONT and DONT need no answer, since we never enable anything without
+	// being asked.
+	return nil
+}
+
+// subnegotiate handles the data sent by the client in a subnegotiation.
+func (c *Conn) subnegotiate(sb []byte) {
+	if len(sb) == 5 && sb[0] == OptNAWS {
+		atomic.StoreInt32(&c.width, int32(sb[1])<<8|int32(sb[2]))
+		atomic.StoreInt32(&c.height, int32(sb[3])<<8|int32(sb[4]))
+	}
+}
+
+// sendMSSP writes the server status to the client.
+func (c *Conn) sendMSSP() error {
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{IAC, SB, OptMSSP})
+	for _, v := range c.MSSP() {
+		buf.WriteByte(msspVar)
+		buf.WriteString(v.Name)
+		for _, val := range v.Values {
+			buf.WriteByte(msspVal)
+			buf.WriteString(val)
+		}
+	}
+	buf.Write([]byte{IAC, SE})
+	_, err := c.Conn.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,82 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestReadStripsCommands(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	c := NewConn(server)
+	defer c.Close()
+
+	go func() {
+		client.Write([]byte{'h', IAC, NOP, 'i', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, IAC, IAC, '\n'})
+	}()
+	buf := make([]byte, 20)
+	got := []byte{}
+	for len(got) < 4 {
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	expected := []byte{'h', 'i', IAC, '\n'}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	w, h := c.Size()
+	if w != 80 || h != 24 {
+		t.Fatalf("expected size 80x24, got %vx%v", w, h)
+	}
+}
+
+func TestMSSP(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	c := NewConn(server)
+	defer c.Close()
+	c.MSSP = func() []Var {
+		return []Var{
+			{Name: "NAME", Values: []string{"ClayMUD"}},
+			{Name: "PLAYERS", Values: []string{"5"}},
+		}
+	}
+
+	go c.Offer()
+	offer := make([]byte, 6)
+	if _, err := io.ReadFull(client, offer); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(offer, []byte{IAC, DO, OptNAWS, IAC, WILL, OptMSSP}) {
+		t.Fatalf("expected DO NAWS and WILL MSSP, got %v", offer)
+	}
+
+	go func() {
+		client.Write([]byte{IAC, DO, OptMSSP})
+	}()
+	go c.Read(make([]byte, 10))
+
+	expected := []byte{IAC, SB, OptMSSP}
+	expected = append(expected, msspVar)
+	expected = append(expected, "NAME"...)
+	expected = append(expected, msspVal)
+	expected = append(expected, "ClayMUD"...)
+	expected = append(expected, msspVar)
+	expected = append(expected, "PLAYERS"...)
+	expected = append(expected, msspVal)
+	expected = append(expected, "5"...)
+	expected = append(expected, IAC, SE)
+
+	got := make([]byte, len(expected))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
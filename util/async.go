@@ -0,0 +1,239 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is returned by an AsyncWriter when the connection it writes to has
+// stopped accepting output.
+var ErrStalled = errors.New("output stalled")
+
+// ErrFlushTimeout is returned by AsyncWriter.Flush when the buffered output
+// wasn't all written in time.
+var ErrFlushTimeout = errors.New("timed out flushing output")
+
+// Counter is a running total that is safe for concurrent use, such as the
+// bytes written by many AsyncWriters.
+type Counter struct {
+	n int64
+}
+
+// Add adds n to the total.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.n, n)
+}
+
+// Total returns the total so far.
+func (c *Counter) Total() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// how long Close waits for buffered output to be written before giving up.
+const closeTimeout = 5 * time.Second
+
+// the notice added to the output when some of it had to be thrown away.
+var truncNotice = []byte("\n*** You are not keeping up, some output was lost. ***\n")
+
+// WriterStats describes the state of an AsyncWriter's buffer.
+type WriterStats struct {
+	Queued      int           // bytes waiting to be written
+	Max         int           // the most bytes that can wait to be written
+	Sent        int64         // bytes written so far
+	Dropped     int64         // bytes thrown away because the buffer was full
+	Truncations int           // how many times output was thrown away
+	Stalled     time.Duration // how long the current write has been blocked
+}
+
+// AsyncWriter writes to an underlying writer from its own goroutine, so that
+// callers never wait on a slow connection.  Output is buffered up to a maximum
+// size.  Anything written past that is thrown away, and a notice is added so
+// the reader knows they missed something.
+type AsyncWriter struct {
+	w     io.WriteCloser
+	max   int
+	stall time.Duration
+	sent  *Counter
+
+	mu      sync.Mutex
+	buf     []byte
+	full    bool      // output has been dropped since the writer last took the buffer
+	writing time.Time // when the current write started, zero if not writing
+	closed  bool
+	err     error
+	stats   WriterStats
+	idle    []chan struct{} // closed when the buffer has all been written
+
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// NewAsyncWriter starts writing to w in the background.  At most max bytes are
+// buffered, and if a single write to w blocks for longer than stall, w is
+// closed.  Zero values for max or stall mean there is no limit.  Bytes written
+// to w are added to sent, if it isn't nil.
+func NewAsyncWriter(w io.WriteCloser, max int, stall time.Duration, sent *Counter) *AsyncWriter {
+	a := &AsyncWriter{
+		w:     w,
+		max:   max,
+		stall: stall,
+		sent:  sent,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write implements io.Writer.  It never blocks on the underlying writer.  It
+// returns an error if an earlier write failed or the writer has been closed.
+func (a *AsyncWriter) Write(b []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return 0, a.err
+	}
+	if a.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if a.max > 0 && len(a.buf)+len(b) > a.max {
+		if a.stall > 0 && !a.writing.IsZero() && time.Since(a.writing) > a.stall {
+			a.err = ErrStalled
+			// closing the underlying writer unblocks the stalled write.
+			go a.w.Close()
+			return 0, a.err
+		}
+		a.stats.Dropped += int64(len(b))
+		if !a.full {
+			a.full = true
+			a.stats.Truncations++
+			a.buf = append(a.buf, truncNotice...)
+			a.signal()
+		}
+		return len(b), nil
+	}
+	a.buf = append(a.buf, b...)
+	a.signal()
+	return len(b), nil
+}
+
+// Close stops accepting output and closes the underlying writer once the
+// buffered output has been written, or after a timeout.  It does not wait for
+// either.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+	a.signal()
+	go func() {
+		select {
+		case <-a.done:
+		case <-time.After(closeTimeout):
+		}
+		a.w.Close()
+	}()
+	return nil
+}
+
+// Flush waits until the buffered output has been written, or the timeout has
+// passed.  It returns ErrFlushTimeout if the output didn't all get written, or
+// the error that stopped the writer, if there was one.
+func (a *AsyncWriter) Flush(timeout time.Duration) error {
+	a.mu.Lock()
+	if a.err != nil || (len(a.buf) == 0 && a.writing.IsZero()) {
+		err := a.err
+		a.mu.Unlock()
+		return err
+	}
+	idle := make(chan struct{})
+	a.idle = append(a.idle, idle)
+	a.mu.Unlock()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-idle:
+	case <-a.done:
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Stats returns the current state of the writer's buffer.
+func (a *AsyncWriter) Stats() WriterStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.stats
+	s.Queued = len(a.buf)
+	s.Max = a.max
+	if !a.writing.IsZero() {
+		s.Stalled = time.Since(a.writing)
+	}
+	return s
+}
+
+// signal wakes up the writer goroutine.  It doesn't block, and is safe to call
+// from any goroutine, with or without a.mu held.
+func (a *AsyncWriter) signal() {
+	select {
+	case a.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the buffered output until the writer is closed or a write fails.
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	var out []byte
+	for range a.ready {
+		for {
+			a.mu.Lock()
+			if len(a.buf) == 0 {
+				closed := a.closed
+				a.mu.Unlock()
+				if closed {
+					return
+				}
+				break
+			}
+			// swap buffers, so writers can keep going while we write.
+			out, a.buf = a.buf, out[:0]
+			a.full = false
+			a.writing = time.Now()
+			a.mu.Unlock()
+
+			n, err := a.w.Write(out)
+
+			a.mu.Lock()
+			a.writing = time.Time{}
+			a.stats.Sent += int64(n)
+			if a.sent != nil {
+				a.sent.Add(int64(n))
+			}
+			if err != nil && a.err == nil {
+				a.err = err
+			}
+			if len(a.buf) == 0 {
+				for _, idle := range a.idle {
+					close(idle)
+				}
+				a.idle = nil
+			}
+			failed := a.err != nil
+			a.mu.Unlock()
+			if failed {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncWriterTruncates(t *testing.T) {
+	r, pw := io.Pipe()
+	w := newStartedWriter(pw)
+	var sent Counter
+	a := NewAsyncWriter(w, 10, 0, &sent)
+
+	// the first write is taken by the writer goroutine, which then blocks on
+	// the pipe, so later writes fill the buffer.
+	a.Write([]byte("first\n"))
+	w.waitStarted(t)
+	a.Write([]byte("12345"))
+	a.Write([]byte("67890abc"))
+	s := a.Stats()
+	if s.Dropped != 8 || s.Truncations != 1 {
+		t.Fatalf("expected 8 bytes dropped in 1 truncation, got %+v", s)
+	}
+	a.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte("first\n12345"), truncNotice...)
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if sent.Total() != int64(len(got)) {
+		t.Errorf("expected the counter to have %d bytes, got %d", len(got), sent.Total())
+	}
+}
+
+func TestAsyncWriterStalls(t *testing.T) {
+	_, pw := io.Pipe()
+	w := newStartedWriter(pw)
+	a := NewAsyncWriter(w, 5, time.Millisecond, nil)
+	a.Write([]byte("hi"))
+	w.waitStarted(t)
+	// nothing reads the pipe, so the write is blocked from here on.  Sleeping
+	// only makes sure it has been blocked for longer than the stall timeout.
+	time.Sleep(2 * time.Millisecond)
+	if _, err := a.Write([]byte("too much output")); err != ErrStalled {
+		t.Fatalf("expected ErrStalled, got %v", err)
+	}
+	// the connection is closed to unblock the stalled write.
+	select {
+	case <-w.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stalled connection to be closed")
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	r, w := io.Pipe()
+	a := NewAsyncWriter(w, 0, 0, nil)
+	// there's nothing to wait for, so even no time at all is enough.
+	if err := a.Flush(0); err != nil {
+		t.Fatalf("expected an empty writer to flush right away, got %v", err)
+	}
+
+	// nothing reads the pipe yet, so the output can't be written, however
+	// long the flush waits.
+	a.Write([]byte("hello"))
+	if err := a.Flush(10 * time.Millisecond); err != ErrFlushTimeout {
+		t.Fatalf("expected ErrFlushTimeout, got %v", err)
+	}
+
+	got := make(chan []byte)
+	go func() {
+		b := make([]byte, 5)
+		io.ReadFull(r, b)
+		got <- b
+	}()
+	if err := a.Flush(5 * time.Second); err != nil {
+		t.Fatalf("expected the output to be flushed, got %v", err)
+	}
+	if b := <-got; string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", b)
+	}
+	if s := a.Stats(); s.Queued != 0 || s.Sent != 5 {
+		t.Errorf("expected everything to be sent after flushing, got %+v", s)
+	}
+}
+
+// startedWriter tells the test when writes to the underlying writer start, and
+// when it's closed, so tests can wait for the writer goroutine without
+// guessing how long it takes.
+type startedWriter struct {
+	io.WriteCloser
+	started chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newStartedWriter(w io.WriteCloser) *startedWriter {
+	return &startedWriter{
+		WriteCloser: w,
+		started:     make(chan struct{}, 1),
+		closed:      make(chan struct{}),
+	}
+}
+
+func (w *startedWriter) Write(b []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	return w.WriteCloser.Write(b)
+}
+
+func (w *startedWriter) Close() error {
+	w.once.Do(func() { close(w.closed) })
+	return w.WriteCloser.Close()
+}
+
+// waitStarted waits for a write to start.
+func (w *startedWriter) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-w.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writer never started writing")
+	}
+}
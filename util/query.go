@@ -24,18 +24,6 @@ type Scanner interface {
 
 // Query writes the question to rw and waits for an answer.
 func Query(ws WriteScanner, question string) (answer string, err error) {
-	// need this because scan can panic if you send it too much stuff
-	defer func() {
-		panicErr := recover()
-		if panicErr == nil {
-			return
-		}
-		if e, ok := panicErr.(error); ok {
-			err = e
-			return
-		}
-		err = fmt.Errorf("%v", panicErr)
-	}()
 	_, err = io.WriteString(ws, question)
 	if err != nil {
 		return "", err
@@ -59,18 +47,6 @@ func QueryVerify(
 	question string,
 	verify func(string) (string, error),
 ) (answer string, err error) {
-	// need this because scan can panic if you send it too much stuff
-	defer func() {
-		panicErr := recover()
-		if panicErr == nil {
-			return
-		}
-		if e, ok := panicErr.(error); ok {
-			err = e
-			return
-		}
-		err = fmt.Errorf("%v", panicErr)
-	}()
 	for {
 		_, err = io.WriteString(ws, question)
 		if err != nil {
@@ -117,17 +93,6 @@ func QueryStrings(
 	defaultIndex int,
 	options ...string,
 ) (index int, err error) {
-	defer func() {
-		panicErr := recover()
-		if panicErr == nil {
-			return
-		}
-		if e, ok := panicErr.(error); ok {
-			err = e
-			return
-		}
-		err = fmt.Errorf("%v", panicErr)
-	}()
 	_, err = io.WriteString(ws, question)
 	if err != nil {
 		return -1, err
@@ -190,19 +155,6 @@ func QueryOptions(
 	Default rune,
 	options ...Opt,
 ) (answer rune, err error) {
-	// need this because scan can panic if you send it too much stuff
-	defer func() {
-		panicErr := recover()
-		if panicErr == nil {
-			return
-		}
-		if e, ok := panicErr.(error); ok {
-			err = e
-			return
-		}
-		err = fmt.Errorf("%v", panicErr)
-	}()
-
 	_, err = io.WriteString(ws, question)
 	if err != nil {
 		return utf8.RuneError, err
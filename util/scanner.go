@@ -0,0 +1,95 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// LineScanner is a Scanner that reads lines of text.  Unlike bufio.Scanner, a
+// line that is too long doesn't stop the scanner, the rest of the line is just
+// thrown away.
+type LineScanner struct {
+	r         *bufio.Reader
+	max       int
+	line      []byte
+	truncated bool
+	err       error
+}
+
+// NewLineScanner returns a LineScanner that reads from r.  Lines longer than
+// max bytes are truncated.  If max is zero, lines can be any length.
+func NewLineScanner(r io.Reader, max int) *LineScanner {
+	return &LineScanner{r: bufio.NewReader(r), max: max}
+}
+
+// Scan reads the next line, which is then available from Text and Bytes.  It
+// returns false when there are no more lines, either because of an error or
+// the end of the input.
+func (s *LineScanner) Scan() bool {
+	s.line = s.line[:0]
+	s.truncated = false
+	if s.err != nil {
+		return false
+	}
+	read := false
+	for {
+		b, err := s.r.ReadSlice('\n')
+		read = read || len(b) > 0
+		s.add(b)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			s.err = err
+			// like bufio.Scanner, return the last line even if it doesn't end
+			// in a newline.
+			return read && err == io.EOF
+		}
+		break
+	}
+	s.line = bytes.TrimSuffix(s.line, []byte("\n"))
+	s.line = bytes.TrimSuffix(s.line, []byte("\r"))
+	return true
+}
+
+// add adds the bytes to the line, dropping anything past the max length.
+func (s *LineScanner) add(b []byte) {
+	if s.max > 0 && len(s.line)+len(b) > s.max {
+		// keep the newline so it gets trimmed like any other.
+		nl := bytes.HasSuffix(b, []byte("\n"))
+		b = b[:s.max-len(s.line)]
+		s.truncated = true
+		if nl {
+			s.line = append(s.line, b...)
+			s.line = append(s.line, '\n')
+			return
+		}
+	}
+	s.line = append(s.line, b...)
+}
+
+// Truncated reports whether the last line was longer than the max length.
+func (s *LineScanner) Truncated() bool {
+	return s.truncated
+}
+
+// Text returns the last line read by Scan.
+func (s *LineScanner) Text() string {
+	return string(s.line)
+}
+
+// Bytes returns the last line read by Scan.  The slice is only valid until the
+// next call to Scan.
+func (s *LineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Err returns the error that stopped the scanner, or nil if it was the end of
+// the input.
+func (s *LineScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineScanner(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	s := NewLineScanner(strings.NewReader("look\r\n"+long+"\nsay hi\nno newline"), 10)
+	expected := []struct {
+		text      string
+		truncated bool
+	}{
+		{text: "look"},
+		{text: "xxxxxxxxxx", truncated: true},
+		{text: "say hi"},
+		{text: "no newline"},
+	}
+	for i, e := range expected {
+		if !s.Scan() {
+			t.Fatalf("line %d: unexpected end of input, err: %v", i, s.Err())
+		}
+		if s.Text() != e.text {
+			t.Errorf("line %d: expected %q, got %q", i, e.text, s.Text())
+		}
+		if s.Truncated() != e.truncated {
+			t.Errorf("line %d: expected truncated to be %v", i, e.truncated)
+		}
+	}
+	if s.Scan() {
+		t.Fatalf("expected end of input, but got %q", s.Text())
+	}
+	if s.Err() != nil {
+		t.Fatalf("unexpected error: %v", s.Err())
+	}
+}
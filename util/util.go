@@ -4,7 +4,10 @@ package util
 import (
 	"fmt"
 	"io"
+	"strings"
 	"text/template"
+	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -34,6 +37,22 @@ func (e *Template) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// Duration is a time.Duration that can be unmarshaled from a string like "5m"
+// in a config file.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText implements TextUnmarshaler.UnmarshalText.
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("can't parse duration %q: %v", text, err)
+	}
+	return nil
+}
+
 type SafeWriter struct {
 	Writer io.Writer
 	OnErr  func(error)
@@ -54,3 +73,42 @@ func (s SafeWriter) WriteString(str string) (int, error) {
 	}
 	return n, nil
 }
+
+// Sizer is implemented by connections that know the size of the user's
+// terminal.
+type Sizer interface {
+	// Size returns the width and height of the terminal, or zero for either
+	// value if it is not known.
+	Size() (width, height int)
+}
+
+// Wrap word wraps each line of s so that it fits in the given width.  A width
+// of zero or less returns s unchanged.  Lines that are already short enough are
+// left alone, so text that was wrapped by hand keeps its formatting.
+func Wrap(width int, s string) string {
+	if width <= 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) <= width {
+			continue
+		}
+		var out []string
+		cur := ""
+		for _, word := range strings.Fields(line) {
+			switch {
+			case cur == "":
+				cur = word
+			case utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width:
+				out = append(out, cur)
+				cur = word
+			default:
+				cur += " " + word
+			}
+		}
+		out = append(out, cur)
+		lines[i] = strings.Join(out, "\n")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,23 @@
+package util
+
+import "testing"
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		width    int
+		in       string
+		expected string
+	}{
+		{0, "no width means no change", "no width means no change"},
+		{10, "short", "short"},
+		{10, "this line is too long", "this line\nis too\nlong"},
+		{10, "keep\nshort lines", "keep\nshort\nlines"},
+		{5, "unbreakablewords stay", "unbreakablewords\nstay"},
+	}
+	for _, test := range tests {
+		got := Wrap(test.width, test.in)
+		if got != test.expected {
+			t.Errorf("Wrap(%v, %q): expected %q, got %q", test.width, test.in, test.expected, got)
+		}
+	}
+}
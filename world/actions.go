@@ -5,21 +5,18 @@ import (
 	"path/filepath"
 
 	"github.com/hippogryph/skyhook"
-	"go.starlark.net/starlark"
+	"github.com/natefinch/claymud/game"
 )
 
-var allActions = map[string]*starlark.Program{}
-
-var actionDir string
-
 // An action is a script that can be run.
 type Action struct {
 	Filename string
 	IsGlobal bool
 }
 
-func InitActions(dir string) error {
-	actionDir = dir
+// initActions sets the directory the world's scripts are loaded from.
+func (w *World) initActions(dir string) {
+	w.actionDir = dir
 	// files, err := ioutil.ReadDir(dir)
 	// if err != nil {
 	// 	return fmt.Errorf("error reading script directory: %v", err)
@@ -41,11 +38,11 @@ func InitActions(dir string) error {
 	// 	log.Println("time to parse a skylark file:", time.Since(start))
 	// 	allActions[f.Name()] = p
 	// }
-	return nil
 }
 
 func runLocAction(name string, actor *Player, loc *Location) error {
-	b, err := ioutil.ReadFile(filepath.Join(actionDir, name))
+	w := loc.World()
+	b, err := ioutil.ReadFile(filepath.Join(w.actionDir, name))
 	if err != nil {
 		return err
 	}
@@ -54,13 +51,25 @@ func runLocAction(name string, actor *Player, loc *Location) error {
 			echo(loc, msg)
 		},
 		"around":   around,
+		"roll":     w.roll,
 		"actor":    actor,
 		"location": loc,
+		"time":     w.Time(),
+		"weather":  loc.Weather().String(),
 	}
 	_, err = skyhook.Eval(b, dict)
 	return err
 }
 
+// roll rolls dice written like 3d6+2, using the world's random numbers.
+func (w *World) roll(dice string) (int, error) {
+	d, err := game.MakeDice(dice)
+	if err != nil {
+		return 0, err
+	}
+	return d.Roll(w.rng), nil
+}
+
 // say something to all the people in the room
 func echo(loc *Location, msg string) {
 	for _, p := range loc.Players {
@@ -69,7 +78,7 @@ func echo(loc *Location, msg string) {
 }
 
 func around(player *Player, msg string) {
-	for _, p := range player.loc.Players {
+	for _, p := range player.Location().Players {
 		if p.ID != player.ID {
 			p.WriteString(msg + "\n")
 		}
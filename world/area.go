@@ -2,8 +2,11 @@ package world
 
 import (
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/util"
 )
 
@@ -27,11 +30,50 @@ func (a *Area) Add(l *Location) {
 // Zone is a collection of Areas that represent one large and logically distinct
 // section of the mud, such as a town.
 type Zone struct {
-	ID     util.ID
-	Name   string
-	Closed bool
-	Areas  []*Area
+	ID    util.ID
+	Name  string
+	Areas []*Area
 	*game.Worker
+
+	world  *World
+	closed int32 // 1 if the zone is closed to players, accessed atomically
+
+	// recentPanics holds when the zone's most recent events panicked.  It is
+	// only used on the zone's worker.
+	recentPanics []time.Time
+
+	// weather is only used on the zone's worker.  wetness and outdoors are
+	// set when the world is loaded.
+	weather  game.Weather
+	wetness  int
+	outdoors []*Location
+}
+
+// A zone that panics this many times within zonePanicWindow is closed.
+const (
+	zonePanicLimit  = 5
+	zonePanicWindow = time.Minute
+)
+
+// panicked is called on the zone's worker when one of its events panics.  If
+// the zone keeps panicking, it is closed so players stop going there.
+func (z *Zone) panicked(origin game.Origin, recovered interface{}) {
+	now := z.world.clock.Now()
+	z.recentPanics = append(z.recentPanics, now)
+	for len(z.recentPanics) > 0 && now.Sub(z.recentPanics[0]) > zonePanicWindow {
+		z.recentPanics = z.recentPanics[1:]
+	}
+	if z.Closed() || len(z.recentPanics) < zonePanicLimit {
+		return
+	}
+	logger.Error("closing zone after too many errors", logging.Zone(z.ID), "errors", len(z.recentPanics), "window", zonePanicWindow, logging.Event("panic"))
+	atomic.StoreInt32(&z.closed, 1)
+}
+
+// Closed reports whether players are kept out of the zone.  It is safe to call
+// from any goroutine.
+func (z *Zone) Closed() bool {
+	return atomic.LoadInt32(&z.closed) == 1
 }
 
 func (z *Zone) String() string {
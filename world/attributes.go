@@ -0,0 +1,130 @@
+package world
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/util"
+)
+
+// AttrValue is one of a player's attributes and its value.
+type AttrValue struct {
+	game.Attribute
+	Value int
+}
+
+// initAttributes checks and sets the attributes every player has.
+func (w *World) initAttributes(attrs game.Attributes) error {
+	// copy before validating, since validating fills in defaults.
+	attrs = append(game.Attributes(nil), attrs...)
+	if err := attrs.Validate(); err != nil {
+		return err
+	}
+	w.attributes = attrs
+	return nil
+}
+
+// playerAttrs returns a player's saved attributes, with the starting value for
+// any attributes added to the game since they were saved.  Attributes that have
+// been taken out of the game are kept, so they aren't lost if they come back.
+func (w *World) playerAttrs(saved map[string]int) map[string]int {
+	attrs := make(map[string]int, len(w.attributes))
+	for name, v := range saved {
+		attrs[name] = v
+	}
+	for _, a := range w.attributes {
+		if _, ok := attrs[a.Name]; !ok {
+			attrs[a.Name] = *a.Start
+		}
+	}
+	return attrs
+}
+
+// rollAttrs rolls the attributes for a new character, with the modifiers for
+// their race and class, letting the user roll again until they're happy with
+// them.
+func (w *World) rollAttrs(user *auth.User, mods ...map[string]int) (map[string]int, error) {
+	for {
+		attrs := w.attributes.Roll(w.rng, mods...)
+		if !w.attributes.Rolled() {
+			return attrs, nil
+		}
+		if _, err := fmt.Fprintf(user, "\nYour attributes are:\n%s", formatAttrs(w.attributes, attrs)); err != nil {
+			return nil, err
+		}
+		a, err := util.QueryOptions(user, "\nKeep these attributes?", 'k',
+			util.Opt{Key: 'k', Text: "Keep them"},
+			util.Opt{Key: 'r', Text: "Roll again"})
+		if err != nil {
+			return nil, err
+		}
+		if a == 'k' {
+			return attrs, nil
+		}
+	}
+}
+
+// formatAttrs lists the attributes one per line with their values.
+func formatAttrs(defs game.Attributes, vals map[string]int) string {
+	width := 0
+	for _, a := range defs {
+		if len(a.Long) > width {
+			width = len(a.Long)
+		}
+	}
+	var b strings.Builder
+	for _, a := range defs {
+		fmt.Fprintf(&b, "  %-*s %d\n", width, a.Long, vals[a.Name])
+	}
+	return b.String()
+}
+
+// Attr returns the value of the player's attribute with the given name,
+// ignoring case, or 0 if there's no such attribute.  Templates can use it like
+// {{ .Actor.Attr "STR" }}.
+func (p *Player) Attr(name string) int {
+	a, ok := p.world.attributes.Find(name)
+	if !ok {
+		return 0
+	}
+	return p.attrs[a.Name]
+}
+
+// Attributes returns the player's attributes in the order they're defined.
+func (p *Player) Attributes() []AttrValue {
+	vals := make([]AttrValue, len(p.world.attributes))
+	for i, a := range p.world.attributes {
+		vals[i] = AttrValue{Attribute: a, Value: p.attrs[a.Name]}
+	}
+	return vals
+}
+
+// defaultScoreTemplate is used when the data directory doesn't have a
+// score.template.  See data/score.template for what it can use.
+const defaultScoreTemplate = `{{ .Name }}, {{ .Gender.Name }}
+{{- with .Race }}
+Race:  {{ . }}
+{{- end }}
+{{- with .Class }}
+Class: {{ . }}
+{{- end }}
+{{- with .Attributes }}
+
+[Attributes]
+{{- range . }}
+{{ printf "%-14s %3d" .Long .Value }}
+{{- end }}
+{{- end }}
+`
+
+// score shows the player their character, using the score template.
+func score(c *Command) {
+	c.Actor.HandleLocal(func() {
+		if err := c.World.scoreTemplate.Execute(c.Actor, c.Actor); err != nil {
+			logger.Error("error showing score", logging.Player(c.Actor.Name()), logging.Err(err))
+		}
+	})
+}
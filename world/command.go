@@ -2,16 +2,16 @@ package world
 
 import (
 	"io"
-	"log"
 	"strings"
 
+	"github.com/natefinch/claymud/game"
 	"github.com/natefinch/claymud/game/social"
+	"github.com/natefinch/claymud/logging"
 )
 
-var chatMode ChatMode
-
 // Command represents a command sent by a player.
 type Command struct {
+	World *World
 	Actor *Player
 	Loc   *Location
 	Cmd   []string
@@ -56,11 +56,11 @@ func (c *Command) Handle() {
 	// prevent yourc from going north... your social just won't work
 
 	actionName := strings.Join(c.Cmd, " ")
-	action, ok := c.Actor.loc.Actions[actionName]
+	action, ok := c.Actor.Location().Actions[actionName]
 	if ok {
 		f := func() {
-			if err := runLocAction(action.Filename, c.Actor, c.Actor.loc); err != nil {
-				log.Printf("error running loc action %q in room %v: %v", actionName, c.Actor.loc.ID, err)
+			if err := runLocAction(action.Filename, c.Actor, c.Actor.Location()); err != nil {
+				scriptLogger.Error("error running loc action", "action", actionName, logging.Player(c.Actor.Name()), logging.Room(c.Actor.Location().ID), logging.Err(err))
 			}
 		}
 		if action.IsGlobal {
@@ -88,7 +88,7 @@ func (c *Command) Handle() {
 	// chatmode is on, so we run directions if they are standalone,
 	// otherwise all commands must be prefixed by the chatmode prefix
 
-	isCmd := strings.HasPrefix(c.Action(), chatMode.Prefix)
+	isCmd := strings.HasPrefix(c.Action(), c.World.chatMode.Prefix)
 	if !isCmd {
 		if c.Target() == "" && c.handleExit() {
 			return
@@ -98,7 +98,7 @@ func (c *Command) Handle() {
 		return
 	}
 	// strip prefix off the command name, so the rest of our string checks work
-	c.Cmd[0] = c.Cmd[0][len(chatMode.Prefix):]
+	c.Cmd[0] = c.Cmd[0][len(c.World.chatMode.Prefix):]
 	if c.run() {
 		return
 	}
@@ -110,7 +110,7 @@ func (c *Command) Handle() {
 }
 
 func (c *Command) run() bool {
-	f, ok := commands[c.Action()]
+	f, ok := c.World.commands[c.Action()]
 	if !ok {
 		return false
 	}
@@ -155,7 +155,8 @@ func (c *Command) handleSocial() (handled bool) {
 		if target != nil {
 			t = target
 		}
-		social.Perform(c.Action(), c.Actor, t, io.MultiWriter(others...))
+		social.Perform(c.Action(), c.Actor, t, c.Loc.setting(), io.MultiWriter(others...))
+		game.Publish(c.World.bus, Socialed{Player: c.Actor, Target: target, Loc: c.Loc, Social: c.Action()})
 	})
 	return true
 }
@@ -10,16 +10,13 @@ import (
 	"time"
 
 	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
 	"github.com/natefinch/claymud/game"
 	"github.com/natefinch/claymud/game/social"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/util"
 )
 
-var started = time.Now()
-
-var commands = map[string]func(*Command){}
-var allCommands []CommandCfg
-
 // Commands lets you configure how the commands get named.  The list of strings for
 // each contain the aliases, they must all be unique.
 type Commands struct {
@@ -32,6 +29,17 @@ type Commands struct {
 	Help,
 	Uptime,
 	ChatMode,
+	SSHKey,
+	Ban,
+	Unban,
+	Shutdown,
+	Reboot,
+	Clear,
+	Netstat,
+	Lag,
+	Time,
+	Weather,
+	Score,
 	Goto CommandCfg
 }
 
@@ -42,46 +50,54 @@ type CommandCfg struct {
 	Help    string
 }
 
-var (
-	helptext     string
-	movementHelp string
-	socialsHelp  string
-)
-
 // initCommands sets up the command names.
-func initCommands(cfg Commands) {
-	register(zones, cfg.Zones)
-	register(look, cfg.Look)
-	register(who, cfg.Who)
-	register(tell, cfg.Tell)
-	register(quit, cfg.Quit)
-	register(say, cfg.Say)
-	register(help, cfg.Help)
-	register(uptime, cfg.Uptime)
-	register(gotoCmd, cfg.Goto)
+func (w *World) initCommands(cfg Commands) {
+	w.register(zones, cfg.Zones)
+	w.register(look, cfg.Look)
+	w.register(who, cfg.Who)
+	w.register(tell, cfg.Tell)
+	w.register(quit, cfg.Quit)
+	w.register(say, cfg.Say)
+	w.register(help, cfg.Help)
+	w.register(uptime, cfg.Uptime)
+	w.register(gotoCmd, cfg.Goto)
+	w.register(sshkey, cfg.SSHKey)
+	w.register(ban, cfg.Ban)
+	w.register(unban, cfg.Unban)
+	w.register(shutdownCmd, cfg.Shutdown)
+	w.register(reboot, cfg.Reboot)
+	w.register(clearCmd, cfg.Clear)
+	w.register(netstat, cfg.Netstat)
+	w.register(lag, cfg.Lag)
+	w.register(timeCmd, cfg.Time)
+	w.register(weather, cfg.Weather)
+	w.register(score, cfg.Score)
+	for _, name := range append(cfg.Clear.Aliases, cfg.Clear.Command) {
+		w.clearNames[strings.ToLower(name)] = true
+	}
 
 	// this is a special "command" that just handles when someone hits enter without typing
 	// anything.
-	commands[""] = prompt
+	w.commands[""] = prompt
 
 	// only register the chatmode command if it's allowed to be run
-	if chatMode.Mode == ChatModeAllow {
-		register(chatmode, cfg.ChatMode)
+	if w.chatMode.Mode == ChatModeAllow {
+		w.register(chatmode, cfg.ChatMode)
 	}
 
-	sort.SliceStable(allCommands, func(i, j int) bool { return allCommands[i].Command < allCommands[j].Command })
+	sort.SliceStable(w.allCommands, func(i, j int) bool { return w.allCommands[i].Command < w.allCommands[j].Command })
 
 	var lines []string
 	lines = append(lines, "-- Commands --")
 
 	buf := &bytes.Buffer{}
-	w := tabwriter.NewWriter(buf, 0, 0, 0, ' ', 0)
+	tw := tabwriter.NewWriter(buf, 0, 0, 0, ' ', 0)
 
-	for _, c := range allCommands {
+	for _, c := range w.allCommands {
 		aliases := append([]string{c.Command}, c.Aliases...)
-		fmt.Fprintf(w, "%s\t  %s\n", strings.Join(aliases, ", "), c.Help)
+		fmt.Fprintf(tw, "%s\t  %s\n", strings.Join(aliases, ", "), c.Help)
 	}
-	if err := w.Flush(); err != nil {
+	if err := tw.Flush(); err != nil {
 		// should be impossible
 		panic(err)
 	}
@@ -90,16 +106,16 @@ func initCommands(cfg Commands) {
 	lines = append(lines, "socials, movement")
 	lines = append(lines, "")
 
-	helptext = strings.Join(lines, "\n")
+	w.helptext = strings.Join(lines, "\n")
 
 	lines = []string{"-- Movement --"}
 	for _, dir := range game.AllDirections() {
 		lines = append(lines, fmt.Sprintf("%v, %v", dir.Name, strings.Join(dir.Aliases, ", ")))
 	}
 	lines = append(lines, "")
-	movementHelp = strings.Join(lines, "\n")
+	w.movementHelp = strings.Join(lines, "\n")
 
-	socialsHelp = `
+	w.socialsHelp = `
 Socials are special commands which will display a predetermined message. 
 Socials can be performed standalone, directed at someone in the room (or
 everyone), or directed at yourself. The same social command may have
@@ -111,15 +127,15 @@ bystanders watching the interaction take place.
 ` + strings.Join(social.Names, "\n") + "\n"
 }
 
-func register(f func(*Command), cmd CommandCfg) {
+func (w *World) register(f func(*Command), cmd CommandCfg) {
 	names := append(cmd.Aliases, cmd.Command)
 	for _, n := range names {
-		if _, ok := commands[n]; ok {
+		if _, ok := w.commands[n]; ok {
 			panic(fmt.Errorf("duplicate command name: %v %#v", n, cmd))
 		}
-		commands[n] = f
+		w.commands[n] = f
 	}
-	allCommands = append(allCommands, cmd)
+	w.allCommands = append(w.allCommands, cmd)
 }
 
 // look handles the look command
@@ -158,7 +174,7 @@ func gotoCmd(c *Command) {
 	}
 	num, err := strconv.Atoi(c.Target())
 	if err == nil {
-		loc, ok := locMap[util.ID(num)]
+		loc, ok := c.World.Location(util.ID(num))
 		if !ok {
 			c.Actor.HandleLocal(func() {
 				c.Actor.WriteString("There is no room with that number.")
@@ -168,13 +184,14 @@ func gotoCmd(c *Command) {
 		c.Actor.Move(loc)
 		return
 	}
-	c.Actor.HandleGlobal(func() {
-		if p, ok := playerMap[c.Target()]; ok {
-			c.Actor.Relocate(p.loc)
-			return
-		}
-		c.Actor.WriteString("There is player with that name.")
-	})
+	p, ok := c.World.FindPlayer(c.Target())
+	if !ok {
+		c.Actor.HandleLocal(func() {
+			c.Actor.WriteString("There is no player with that name.")
+		})
+		return
+	}
+	c.Actor.Move(p.Location())
 }
 
 // chatModeSay is when someone types non-command text in chatmode.
@@ -194,19 +211,27 @@ func doChat(msg string, c *Command) {
 		}
 	}
 	c.Actor.WriteString(toOthers)
+	game.Publish(c.World.bus, Said{Player: c.Actor, Loc: c.Loc, Msg: msg})
 }
 
 func tell(c *Command) {
-	c.Actor.HandleGlobal(func() {
-		target, ok := FindPlayer(c.Target())
-		if ok {
-			msg := strings.Join(c.Cmd[2:], " ")
-			target.Printf("%v tells you: %v", c.Actor.Name(), msg)
-			target.prompt()
-			c.Actor.Printf("You tell %v: %v", target.Name(), msg)
-		} else {
+	target, ok := c.World.FindPlayer(c.Target())
+	if !ok {
+		c.Actor.HandleLocal(func() {
 			c.Actor.WriteString("No one with that name exists.")
-		}
+		})
+		return
+	}
+	msg := strings.Join(c.Cmd[2:], " ")
+	// the target may be in another zone, so they hear it on their own zone's
+	// worker.
+	target.post(func() {
+		target.Printf("%v tells you: %v", c.Actor.Name(), msg)
+		target.prompt()
+	})
+	c.Actor.HandleLocal(func() {
+		c.Actor.Printf("You tell %v: %v", target.Name(), msg)
+		game.Publish(c.World.bus, Told{From: c.Actor, To: target, Msg: msg})
 	})
 }
 
@@ -215,16 +240,23 @@ func help(c *Command) {
 		if c.Target() != "" {
 			c.helpdetails(c.Target())
 		} else {
-			c.Actor.WriteString(helptext)
+			c.Actor.WriteString(c.World.helptext)
 		}
 	})
 }
 
 func who(c *Command) {
-	c.Actor.HandleGlobal(func() {
+	c.Actor.HandleLocal(func() {
 		c.Actor.WriteString("[Players]\n")
-		for _, p := range *playerList {
-			c.Actor.WriteString(p.Name() + "\n")
+		for _, p := range c.World.players.list() {
+			line := p.Name()
+			if kind := strings.TrimSpace(p.Race() + " " + p.Class()); kind != "" {
+				line += " (" + kind + ")"
+			}
+			if p.LinkDead() {
+				line += " (linkdead)"
+			}
+			c.Actor.WriteString(line + "\n")
 		}
 	})
 }
@@ -258,7 +290,7 @@ func chatmode(c *Command) {
 
 func uptime(c *Command) {
 	c.Actor.HandleLocal(func() {
-		d := time.Since(started)
+		d := c.World.clock.Now().Sub(c.World.started)
 		switch {
 		case d > 48*time.Hour:
 			c.Actor.Printf("%d days", int(d.Hours()/24))
@@ -272,19 +304,171 @@ func uptime(c *Command) {
 	})
 }
 
+// timeCmd tells the player what time it is in the game.
+func timeCmd(c *Command) {
+	c.Actor.HandleLocal(func() {
+		t := c.World.Time()
+		c.Actor.Printf("It is %s.\n", t)
+		if s := t.Season().Name; s != "" {
+			c.Actor.Printf("It is %s.\n", strings.ToLower(s))
+		}
+	})
+}
+
+// weather tells the player what the sky looks like, if they can see it.
+func weather(c *Command) {
+	c.Actor.HandleLocal(func() {
+		if sky := c.Loc.Sky(); sky != "" {
+			c.Actor.WriteString(sky + "\n")
+		} else {
+			c.Actor.WriteString("You can't see the sky from here.\n")
+		}
+	})
+}
+
 func quit(c *Command) {
 	// this must be done on the player's goroutine.
 	c.Actor.handleQuit()
 }
 
+// sshkey lets users manage the public keys they can use to log in with SSH.
+func sshkey(c *Command) {
+	// this talks to the db, so it runs on the player's goroutine, and only
+	// the output goes through the worker.
+	var msg string
+	switch c.Target() {
+	case "":
+		keys, err := auth.Keys(c.Actor.st, c.Actor.Username)
+		switch {
+		case err != nil:
+			logger.Error("error listing ssh keys", logging.User(c.Actor.Username), logging.Err(err))
+			msg = "Error listing your keys."
+		case len(keys) == 0:
+			msg = "You have no SSH keys."
+		default:
+			lines := []string{"-- SSH Keys --"}
+			for i, k := range keys {
+				lines = append(lines, fmt.Sprintf("%d - %s", i+1, k))
+			}
+			msg = strings.Join(lines, "\n")
+		}
+	case "add":
+		if err := auth.AddKey(c.Actor.st, c.Actor.Username, c.Text(true)); err != nil {
+			msg = err.Error()
+		} else {
+			msg = "Key added."
+		}
+	case "remove":
+		n, err := strconv.Atoi(c.Text(true))
+		if err != nil {
+			msg = "Remove which key?  Use the number from the list of your keys."
+			break
+		}
+		if err := auth.RemoveKey(c.Actor.st, c.Actor.Username, n-1); err != nil {
+			msg = err.Error()
+		} else {
+			msg = "Key removed."
+		}
+	default:
+		msg = "Usage: sshkey [add <public key> | remove <number>]"
+	}
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString(msg)
+	})
+}
+
+// ban is an admin command that lists the banned sites, or bans a new one.
+func ban(c *Command) {
+	if !c.requireAdmin() {
+		return
+	}
+	var msg string
+	if c.Target() == "" {
+		msg = listBans(c.Actor.st)
+	} else {
+		msg = addBan(c)
+	}
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString(msg)
+	})
+}
+
+func listBans(st *db.Store) string {
+	bans, err := st.Bans()
+	if err != nil {
+		logger.Error("error listing bans", logging.Err(err))
+		return "Error listing bans."
+	}
+	if len(bans) == 0 {
+		return "There are no banned sites."
+	}
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Site\tLevel\tBy\tDate\tReason")
+	for _, b := range bans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.Net, b.Level, b.By, b.Created.Format("2006-01-02"), b.Reason)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+func addBan(c *Command) string {
+	if len(c.Cmd) < 3 {
+		return "Usage: ban <ip or cidr> <all|new|notice> [reason]"
+	}
+	level, err := db.ParseBanLevel(c.Cmd[2])
+	if err != nil {
+		return err.Error()
+	}
+	b, err := auth.Ban(c.Actor.st, c.Cmd[1], level, strings.Join(c.Cmd[3:], " "), c.Actor.Username)
+	if err != nil {
+		return err.Error()
+	}
+	logger.Info("banned site", logging.Player(c.Actor.Name()), "ban", b.Net, "level", b.Level, logging.Event("ban"))
+	return fmt.Sprintf("Banned %s (%s).", b.Net, b.Level)
+}
+
+// unban is an admin command that removes a ban.
+func unban(c *Command) {
+	if !c.requireAdmin() {
+		return
+	}
+	var msg string
+	if err := auth.Unban(c.Actor.st, c.Target()); err != nil {
+		if _, ok := err.(db.ErrNotFound); ok {
+			msg = "That site is not banned."
+		} else {
+			msg = err.Error()
+		}
+	} else {
+		logger.Info("removed ban", logging.Player(c.Actor.Name()), "ban", c.Target(), logging.Event("ban"))
+		msg = "Ban removed."
+	}
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString(msg)
+	})
+}
+
+// requireAdmin reports whether the actor is an admin.  If not, it tells the
+// actor they can't run the command.
+func (c *Command) requireAdmin() bool {
+	if c.Actor.User.Flag(auth.UFlagAdmin) {
+		return true
+	}
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString(`"` + c.Action() + `"` + " is not a valid command.")
+	})
+	return false
+}
+
 func (c *Command) helpdetails(command string) {
 	switch strings.ToLower(command) {
 	case "socials":
-		c.Actor.WriteString(socialsHelp)
+		c.Actor.WriteString(c.World.socialsHelp)
 	case "movement":
-		c.Actor.WriteString(movementHelp)
+		c.Actor.WriteString(c.World.movementHelp)
 	default:
-		for _, cmd := range allCommands {
+		for _, cmd := range c.World.allCommands {
 			if command == cmd.Command || contains(command, cmd.Aliases) {
 				c.Actor.WriteString(cmd.Help)
 				break
@@ -303,19 +487,50 @@ func contains(s string, vals []string) bool {
 }
 
 func zones(c *Command) {
-	c.Actor.WriteString("-- Zones --\n")
-	for _, z := range allZones {
-		if c.Actor.User.Flag(auth.UFlagAdmin) {
-			c.Actor.WriteString(z.Name)
-			if z.Closed {
-				c.Actor.WriteString(" [closed]")
-			}
-			c.Actor.WriteString("\n")
-		} else {
-			if !z.Closed {
-				c.Actor.WriteString(z.Name + "\n")
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString("-- Zones --\n")
+		for _, z := range c.World.allZones {
+			if c.Actor.User.Flag(auth.UFlagAdmin) {
+				c.Actor.WriteString(z.Name)
+				if z.Closed() {
+					c.Actor.WriteString(" [closed]")
+				}
+				if n := z.Panics(); n > 0 {
+					c.Actor.Printf(" (%d errors)", n)
+				}
+				c.Actor.WriteString("\n")
+			} else {
+				if !z.Closed() {
+					c.Actor.WriteString(z.Name + "\n")
+				}
 			}
 		}
+		c.Actor.WriteString("\n")
+	})
+}
+
+// netstat shows admins the state of each player's output buffer.
+func netstat(c *Command) {
+	if !c.requireAdmin() {
+		return
 	}
-	c.Actor.WriteString("\n")
+	c.Actor.HandleGlobal(func() {
+		buf := &bytes.Buffer{}
+		w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Player\tQueued\tSent\tDropped\tTruncated\tStalled")
+		for _, p := range c.World.players.list() {
+			if p.LinkDead() {
+				fmt.Fprintf(w, "%s\t-\t-\t-\t-\tlinkdead\n", p.Name())
+				continue
+			}
+			s := p.User.OutputStats()
+			stalled := "-"
+			if s.Stalled >= time.Second {
+				stalled = s.Stalled.Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%d/%d\t%d\t%d\t%d\t%s\n", p.Name(), s.Queued, s.Max, s.Sent, s.Dropped, s.Truncations, stalled)
+		}
+		w.Flush()
+		c.Actor.WriteString(buf.String())
+	})
 }
@@ -0,0 +1,99 @@
+package world
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/natefinch/claymud/util"
+)
+
+// ZoneInfo describes a zone and how busy its worker is, for debugging.
+type ZoneInfo struct {
+	ID      util.ID
+	Name    string
+	Closed  bool
+	Players int   // players in the zone, including link-dead ones
+	Queued  int   // events waiting for the worker's next tick
+	Timers  int   // timers waiting to fire on the worker
+	Events  int64 // events the worker has handled
+	Panics  int64 // events that panicked
+
+	MaxQueueWait time.Duration // the longest an event waited to be handled
+}
+
+// Zones describes each zone, ordered by ID.  It doesn't wait on any worker, so
+// it still answers when the world is stuck.  It is safe to call from any
+// goroutine.
+func (w *World) Zones() []ZoneInfo {
+	players := map[*Zone]int{}
+	for _, p := range w.players.list() {
+		players[p.Location().Area.Zone]++
+	}
+	zones := w.sortedZones()
+	infos := make([]ZoneInfo, 0, len(zones))
+	for _, z := range zones {
+		stats := z.Stats()
+		infos = append(infos, ZoneInfo{
+			ID:           z.ID,
+			Name:         z.Name,
+			Closed:       z.Closed(),
+			Players:      players[z],
+			Queued:       z.Queued(),
+			Timers:       z.Timers(),
+			Events:       stats.Events,
+			Panics:       stats.Panics,
+			MaxQueueWait: stats.MaxQueueWait,
+		})
+	}
+	return infos
+}
+
+// SessionInfo describes a player's connection, for debugging.
+type SessionInfo struct {
+	Player   string
+	Username string
+	Addr     string // where the player connected from, if it's known
+	Room     util.ID
+	Zone     util.ID
+	LinkDead bool
+	Idle     time.Duration // how long since the player typed something
+	Output   util.WriterStats
+}
+
+// Sessions describes each player in the world, ordered by name.  It waits for
+// the global worker, and returns ctx's error if ctx is done first, so a stuck
+// world can't hang the caller.
+func (w *World) Sessions(ctx context.Context) ([]SessionInfo, error) {
+	// buffered, so the worker doesn't block if nobody is waiting anymore.
+	done := make(chan []SessionInfo, 1)
+	w.global.Handle(func() {
+		now := w.clock.Now()
+		var infos []SessionInfo
+		for _, p := range w.players.list() {
+			loc := p.Location()
+			info := SessionInfo{
+				Player:   p.Name(),
+				Username: p.Username,
+				Room:     loc.ID,
+				Zone:     loc.Area.Zone.ID,
+				LinkDead: p.LinkDead(),
+				Idle:     now.Sub(time.Unix(0, atomic.LoadInt64(&p.lastInput))),
+			}
+			if !info.LinkDead {
+				info.Output = p.User.OutputStats()
+				if addr := p.User.Addr(); addr != nil {
+					info.Addr = addr.String()
+				}
+			}
+			infos = append(infos, info)
+		}
+		done <- infos
+	})
+	select {
+	case infos := <-done:
+		return infos, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package world
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// directory holds the players in the world.  It is safe to use from any
+// goroutine, so commands like tell and who can look players up from their own
+// zone's worker instead of stopping the world to do it.
+type directory struct {
+	mu     sync.RWMutex
+	byName map[string]*Player
+	byUser map[string]*Player
+
+	// sorted is never changed in place, only replaced, so the slice returned
+	// by list can be read after the lock is released.
+	sorted sortedPlayers
+}
+
+// newDirectory returns an empty directory.
+func newDirectory() *directory {
+	return &directory{
+		byName: map[string]*Player{},
+		byUser: map[string]*Player{},
+	}
+}
+
+// add puts the player in the directory.
+func (d *directory) add(p *Player) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.byName[strings.ToLower(p.Name())] = p
+	d.byUser[p.Username] = p
+	sorted := append(sortedPlayers(nil), d.sorted...)
+	sorted.add(p)
+	d.sorted = sorted
+}
+
+// remove takes the player out of the directory.
+func (d *directory) remove(p *Player) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.byName, strings.ToLower(p.Name()))
+	if d.byUser[p.Username] == p {
+		delete(d.byUser, p.Username)
+	}
+	sorted := append(sortedPlayers(nil), d.sorted...)
+	sorted.remove(p)
+	d.sorted = sorted
+}
+
+// find returns the player with the given name, ignoring case.
+func (d *directory) find(name string) (*Player, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	p, ok := d.byName[strings.ToLower(name)]
+	return p, ok
+}
+
+// user returns the player the user is currently playing.
+func (d *directory) user(username string) (*Player, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	p, ok := d.byUser[username]
+	return p, ok
+}
+
+// list returns the players sorted by name.  The slice must not be modified.
+func (d *directory) list() []*Player {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sorted
+}
+
+// len returns the number of players in the directory.
+func (d *directory) len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.sorted)
+}
+
+type sortedPlayers []*Player
+
+func (s *sortedPlayers) add(p *Player) {
+	*s = append(*s, p)
+	sort.SliceStable(*s, func(i, j int) bool { return (*s)[i].Name() < (*s)[j].Name() })
+}
+func (s *sortedPlayers) remove(p *Player) {
+	for i, pl := range *s {
+		if pl.Is(p) {
+			*s = append((*s)[:i], (*s)[i+1:]...)
+			break
+		}
+	}
+}
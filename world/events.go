@@ -0,0 +1,84 @@
+package world
+
+import (
+	"github.com/natefinch/claymud/game"
+)
+
+// These are the events the world publishes on its bus.  Subscribe to them with
+// game.Subscribe(w.Events(), worker, fn).
+//
+// Events hold the players and locations involved.  A player's name and ID are
+// safe to use from any goroutine.  Anything else about them belongs to the
+// worker that runs their zone, or the global worker, so a subscriber that looks
+// further should subscribe on that worker.
+
+// LoggedIn is published when a player joins the world, whether they are new,
+// back after a reboot, or back after losing their link.
+type LoggedIn struct {
+	Player    *Player
+	Loc       *Location
+	Reconnect bool // the player was link-dead and their user logged back in
+}
+
+// LoggedOut is published when a player leaves the world.
+type LoggedOut struct {
+	Player *Player
+	Loc    *Location
+}
+
+// LinkLost is published when a player's connection drops and they stay in the
+// world waiting for their user to log back in.
+type LinkLost struct {
+	Player *Player
+	Loc    *Location
+}
+
+// PlayerEntered is published when a player comes into a location, by logging
+// in or moving.  From is nil if they just logged in.
+type PlayerEntered struct {
+	Player *Player
+	Loc    *Location
+	From   *Location
+}
+
+// PlayerLeft is published when a player goes out of a location, by logging
+// out or moving.  To is nil if they logged out.
+type PlayerLeft struct {
+	Player *Player
+	Loc    *Location
+	To     *Location
+}
+
+// Moved is published when a player moves from one location to another.  It
+// comes after the PlayerLeft and PlayerEntered for the move.
+type Moved struct {
+	Player   *Player
+	From, To *Location
+}
+
+// Said is published when a player says something to the room.
+type Said struct {
+	Player *Player
+	Loc    *Location
+	Msg    string
+}
+
+// Told is published when a player tells something to another player.
+type Told struct {
+	From, To *Player
+	Msg      string
+}
+
+// Socialed is published when a player uses a social.  Target is nil if the
+// social had no target.
+type Socialed struct {
+	Player *Player
+	Target *Player
+	Loc    *Location
+	Social string
+}
+
+// Events returns the bus the world publishes its events on.
+func (w *World) Events() *game.Bus {
+	return w.bus
+}
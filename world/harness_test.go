@@ -0,0 +1,388 @@
+package world
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/game/social"
+)
+
+// expectTimeout is how long Expect waits for output before failing the test.
+const expectTimeout = 5 * time.Second
+
+// fixtureDir holds a tiny world for the integration tests:
+//
+//	                 Windy Alley (101, push button runs wind.star)
+//	                      |
+//	Town Hall (102) --- Town Square (100, start) --- Forest Edge (200) --- Locked Vault (300)
+//	   indoors            zone 1                       zone 2                zone 3, closed
+const fixtureDir = "testdata"
+
+// the directions, genders, and socials are global, so they are only loaded
+// once for all the tests.
+var (
+	globalsOnce sync.Once
+	globalsErr  error
+)
+
+func initGlobals() error {
+	globalsOnce.Do(func() {
+		var cfg struct {
+			Direction []game.Direction
+			Gender    []game.Gender
+		}
+		if _, err := toml.DecodeFile("../data/mud.toml", &cfg); err != nil {
+			globalsErr = err
+			return
+		}
+		game.InitDirs(cfg.Direction)
+		game.InitGenders(cfg.Gender)
+		globalsErr = social.Initialize(fixtureDir)
+	})
+	return globalsErr
+}
+
+// harness runs a full world loaded from the fixture data, with a fresh
+// database, and connects clients to it the same way the server does.
+type harness struct {
+	t        testing.TB
+	w        *World
+	auth     *auth.Service
+	st       *db.Store
+	dir      string
+	shutdown chan struct{}
+	wg       *sync.WaitGroup // the world's workers
+	sessions sync.WaitGroup  // the clients' logins and players
+	clients  []*client
+}
+
+// newHarness starts a world from the fixture data.  Call close when the test is
+// done with it.
+func newHarness(t testing.TB) *harness {
+	t.Helper()
+	return newHarnessWith(t, nil)
+}
+
+// newHarnessWith is like newHarness, but lets the caller change the world's
+// config before it starts.
+func newHarnessWith(t testing.TB, configure func(*Config)) *harness {
+	t.Helper()
+	if err := initGlobals(); err != nil {
+		t.Fatal(err)
+	}
+	var cmds Commands
+	if _, err := toml.DecodeFile("../data/commands.toml", &cmds); err != nil {
+		t.Fatal(err)
+	}
+	var attrs struct {
+		Attribute game.Attributes
+	}
+	if _, err := toml.DecodeFile(filepath.Join(fixtureDir, "attributes.toml"), &attrs); err != nil {
+		t.Fatal(err)
+	}
+	var races struct {
+		Race game.Races
+	}
+	if _, err := toml.DecodeFile(filepath.Join(fixtureDir, "races.toml"), &races); err != nil {
+		t.Fatal(err)
+	}
+	var classes struct {
+		Class game.Classes
+	}
+	if _, err := toml.DecodeFile(filepath.Join(fixtureDir, "classes.toml"), &classes); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := db.Init(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	h := &harness{
+		t:        t,
+		st:       st,
+		dir:      dir,
+		shutdown: make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+	}
+	cfg := Config{
+		StartRoom:  100,
+		Commands:   cmds,
+		ChatMode:   ChatMode{Mode: ChatModeAllow, Prefix: "/"},
+		Input:      Input{QueueSize: 20},
+		Calendar:   game.DefaultCalendar,
+		Attributes: attrs.Attribute,
+		Races:      races.Race,
+		Classes:    classes.Class,
+	}
+	// tests move game time along with passHours, so the clock never should.
+	cfg.Calendar.HourLength = 1000 * time.Hour
+	if configure != nil {
+		configure(&cfg)
+	}
+	h.w, err = Init(cfg, fixtureDir, h.shutdown, h.wg)
+	if err != nil {
+		h.close()
+		t.Fatal(err)
+	}
+	h.auth, err = auth.New(auth.Config{
+		Title:         "Welcome to the test MUD.\n",
+		BcryptCost:    bcrypt.MinCost,
+		MaxLineLength: 1024,
+		Active:        h.w.Active,
+		Sent:          h.w.Sent(),
+	})
+	if err != nil {
+		h.close()
+		t.Fatal(err)
+	}
+	return h
+}
+
+// close disconnects all the clients, stops the world, and removes the database.
+func (h *harness) close() {
+	for _, c := range h.clients {
+		c.conn.Close()
+	}
+	h.sessions.Wait()
+	close(h.shutdown)
+	h.wg.Wait()
+	h.st.Close()
+	os.RemoveAll(h.dir)
+}
+
+// connect creates an account and a male human warrior with the given name, and
+// waits for the character to arrive at the start room.  As on a brand new MUD,
+// the first client to connect is an admin.
+func (h *harness) connect(name string) *client {
+	h.t.Helper()
+	c := h.create(name, "1", "1")
+	c.Expect("Town Square")
+	c.Expect(">")
+	return c
+}
+
+// create creates an account and a male character with the given name, choosing
+// the race and class with the given menu answers, and waits for the character to
+// arrive.
+func (h *harness) create(name, race, class string) *client {
+	h.t.Helper()
+	c := h.dial(name)
+	if len(h.clients) == 1 {
+		c.Expect("Greetings, Administrator.")
+	} else {
+		c.Expect("Log in with existing account")
+		c.Send("c")
+	}
+	c.Expect("Username: ")
+	c.Send(strings.ToLower(name))
+	c.Expect("Password: ")
+	c.Send("password")
+	c.Expect("By what name do you wish your character to be known? ")
+	c.Send(name)
+	c.Expect("What should this character's gender be?")
+	c.Send("1")
+	c.Expect("What race should this character be?")
+	c.Send(race)
+	c.Expect("What class should this character be?")
+	c.Send(class)
+	c.Expect("Keep these attributes?")
+	c.Send("k")
+	c.Expect("You arrive in a puff of smoke.")
+	return c
+}
+
+// login logs in to the account made by connect for the given name, stopping
+// once the password has been sent.
+func (h *harness) login(name string) *client {
+	h.t.Helper()
+	c := h.dial(name)
+	c.Expect("Log in with existing account")
+	c.Send("l")
+	c.Expect("Username: ")
+	c.Send(strings.ToLower(name))
+	c.Expect("Password: ")
+	c.Send("password")
+	return c
+}
+
+// passHours moves game time forward n hours, as if the clock had.
+func (h *harness) passHours(n int) {
+	h.w.global.Handle(func() {
+		h.w.advanceTime(atomic.LoadInt64(&h.w.hours) + int64(n))
+	})
+}
+
+// keepStepping steps a world created with Config.Manual in the background, until
+// the returned function is called.  Holding mu pauses the stepping.
+func (h *harness) keepStepping(mu *sync.Mutex) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			mu.Lock()
+			h.w.Step()
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// dial connects a new client to the world over an in-memory pipe.  The server
+// end logs in and runs the player just like a telnet connection.
+func (h *harness) dial(name string) *client {
+	server, conn := net.Pipe()
+	c := &client{
+		t:     h.t,
+		name:  name,
+		conn:  conn,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	h.clients = append(h.clients, c)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000 + len(h.clients)}
+	h.sessions.Add(1)
+	go func() {
+		defer h.sessions.Done()
+		user, err := h.auth.Login(h.st, server, addr)
+		if err != nil {
+			server.Close()
+			return
+		}
+		h.w.SpawnPlayer(h.st, user)
+	}()
+	go c.read()
+	return c
+}
+
+// client is the user's end of a connection to the harness's world.
+type client struct {
+	t    testing.TB
+	name string
+	conn net.Conn
+
+	mu  sync.Mutex
+	buf string // output that hasn't been matched by Expect yet
+
+	ready chan struct{} // signaled when more output arrives
+	done  chan struct{} // closed when the connection closes
+}
+
+// read collects everything the world sends to the client.
+func (c *client) read() {
+	defer close(c.done)
+	b := make([]byte, 4096)
+	for {
+		n, err := c.conn.Read(b)
+		if n > 0 {
+			c.mu.Lock()
+			c.buf += string(b[:n])
+			c.mu.Unlock()
+			select {
+			case c.ready <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Send types a line, as if the user had hit enter.
+func (c *client) Send(line string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		c.t.Fatalf("%s can't send %q: %v", c.name, line, err)
+	}
+}
+
+// Expect waits for the client to be sent s, and fails the test if it isn't
+// sent in time.  Output up to and including s is consumed, so the next Expect
+// only looks at what came after it.  It returns the output that came before s.
+func (c *client) Expect(s string) string {
+	c.t.Helper()
+	timeout := time.After(expectTimeout)
+	for {
+		c.mu.Lock()
+		i := strings.Index(c.buf, s)
+		var before string
+		if i >= 0 {
+			before = c.buf[:i]
+			c.buf = c.buf[i+len(s):]
+		}
+		buf := c.buf
+		c.mu.Unlock()
+		if i >= 0 {
+			return before
+		}
+		select {
+		case <-c.ready:
+		case <-c.done:
+			// pick up anything written just before the close.
+			c.mu.Lock()
+			found := strings.Contains(c.buf, s)
+			c.mu.Unlock()
+			if !found {
+				c.t.Fatalf("%s's connection closed without seeing %q, got:\n%s", c.name, s, buf)
+			}
+		case <-timeout:
+			c.t.Fatalf("%s never saw %q, got:\n%s", c.name, s, buf)
+		}
+	}
+}
+
+// skewClock is the system clock, moved forward by however much the test has
+// skipped.  Unlike a ManualClock, the workers keep ticking in real time.
+type skewClock struct {
+	skew int64 // nanoseconds, accessed atomically
+}
+
+func (c *skewClock) Now() time.Time {
+	return time.Now().Add(time.Duration(atomic.LoadInt64(&c.skew)))
+}
+
+func (c *skewClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// Skip moves the clock forward by d.
+func (c *skewClock) Skip(d time.Duration) {
+	atomic.AddInt64(&c.skew, int64(d))
+}
+
+// ExpectClosed waits for the world to close the client's connection, and fails
+// the test if it isn't closed in time.
+func (c *client) ExpectClosed() {
+	c.t.Helper()
+	select {
+	case <-c.done:
+	case <-time.After(expectTimeout):
+		c.t.Fatalf("%s's connection was never closed", c.name)
+	}
+}
@@ -0,0 +1,108 @@
+package world
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/util"
+)
+
+// how often we check for idle players.
+const idleCheckInterval = 5 * time.Second
+
+// Idle configures what happens to players that stop typing.  A zero duration
+// disables that step.
+type Idle struct {
+	Warn     time.Duration // how long until a player is warned they are idle
+	Void     time.Duration // how long until a player is moved to the void room
+	Timeout  time.Duration // how long until a player is disconnected
+	VoidRoom util.ID       // the room idle players are moved to
+}
+
+// idleState is how far along a player is toward being timed out.
+type idleState int32
+
+const (
+	idleActive idleState = iota
+	idleWarned
+	idleVoided
+)
+
+// initIdle sets up the idle timeouts.  It must be run after the world is
+// loaded.
+func (w *World) initIdle(cfg Idle) error {
+	w.idleCfg = cfg
+	if cfg.Void == 0 {
+		return nil
+	}
+	loc, ok := w.locMap[cfg.VoidRoom]
+	if !ok {
+		return fmt.Errorf("idle void room %v does not exist", cfg.VoidRoom)
+	}
+	w.voidRoom = loc
+	return nil
+}
+
+// watchIdle periodically checks for idle players on the global worker.
+func (w *World) watchIdle() {
+	cfg := w.idleCfg
+	if cfg.Warn == 0 && cfg.Void == 0 && cfg.Timeout == 0 {
+		return
+	}
+	w.global.Every(idleCheckInterval, w.checkIdle)
+}
+
+// checkIdle warns, voids, or times out idle players.  It must be run on the
+// global worker.
+func (w *World) checkIdle() {
+	cfg := w.idleCfg
+	now := w.clock.Now()
+	for _, p := range w.players.list() {
+		if p.User.Flag(auth.UFlagAdmin) || p.LinkDead() {
+			continue
+		}
+		idle := now.Sub(time.Unix(0, atomic.LoadInt64(&p.lastInput)))
+		state := idleState(atomic.LoadInt32(&p.idle))
+		switch {
+		case cfg.Timeout > 0 && idle >= cfg.Timeout:
+			logger.Info("timing out idle player", logging.Player(p.Name()), "idle", idle.Round(time.Second), logging.Event("idle"))
+			p.timeout()
+		case cfg.Void > 0 && idle >= cfg.Void && state < idleVoided:
+			atomic.StoreInt32(&p.idle, int32(idleVoided))
+			if p.Location() == w.voidRoom {
+				continue
+			}
+			p.WriteString("You have been idle too long, and fade into the void.\n")
+			p.voidFrom = p.Location()
+			p.Relocate(w.voidRoom)
+			p.prompt()
+		case cfg.Warn > 0 && idle >= cfg.Warn && state < idleWarned:
+			atomic.StoreInt32(&p.idle, int32(idleWarned))
+			p.WriteString("You have been idle for a while.  Type something or you will be disconnected soon.\n")
+			p.prompt()
+		}
+	}
+}
+
+// touch records that the player typed something.  If the player had been
+// marked idle, they are returned to normal before their command runs.
+func (p *Player) touch() {
+	atomic.StoreInt64(&p.lastInput, p.world.clock.Now().UnixNano())
+	if idleState(atomic.LoadInt32(&p.idle)) == idleActive {
+		return
+	}
+	done := make(chan struct{})
+	p.world.global.Handle(func() {
+		defer close(done)
+		atomic.StoreInt32(&p.idle, int32(idleActive))
+		if p.voidFrom != nil {
+			p.WriteString("You return from the void.\n")
+			p.Relocate(p.voidFrom)
+			p.voidFrom = nil
+		}
+	})
+	<-done
+}
@@ -0,0 +1,59 @@
+package world
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdle(t *testing.T) {
+	clock := &skewClock{}
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.Clock = clock
+		cfg.Idle = Idle{
+			Warn:     time.Minute,
+			Void:     2 * time.Minute,
+			Timeout:  3 * time.Minute,
+			VoidRoom: 102,
+		}
+	})
+	defer h.close()
+	// the first player is an admin, and admins never go idle.
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	// checkIdle also runs on a timer, but running it here means the test
+	// doesn't have to wait for it.
+	check := func() { h.w.global.Handle(h.w.checkIdle) }
+
+	clock.Skip(time.Minute)
+	check()
+	bob.Expect("You have been idle for a while.")
+
+	clock.Skip(time.Minute)
+	check()
+	bob.Expect("You have been idle too long, and fade into the void.")
+	p, ok := h.w.players.find("Bob")
+	if !ok {
+		t.Fatal("can't find Bob")
+	}
+	if loc := p.Location(); loc.ID != 102 {
+		t.Errorf("expected Bob to be in the void room, but Bob is in %v", loc.ID)
+	}
+
+	// typing anything brings them back.
+	bob.Send("look")
+	bob.Expect("You return from the void.")
+	bob.Expect("Town Square")
+
+	clock.Skip(3 * time.Minute)
+	check()
+	bob.Expect("You have timed out... good bye!")
+	bob.ExpectClosed()
+
+	// the admin is never warned.
+	alice.Send("look")
+	if out := alice.Expect("Town Square"); strings.Contains(out, "idle") {
+		t.Errorf("expected the admin not to be idle, got:\n%s", out)
+	}
+}
@@ -2,7 +2,12 @@
 package world
 
 import (
+	"path/filepath"
 	"sync"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/util"
 )
 
 // ChatModeMode determines whether ChatMode is allowed to be on, required to be on, or not allowed to be on.
@@ -27,26 +32,108 @@ type Config struct {
 	StartRoom int      // ID of room players start in
 	Commands  Commands // command names
 	ChatMode  ChatMode
+	Idle      Idle
+
+	// LinkDeadGrace is how long players who lose their connection stay in the
+	// world waiting for the user to reconnect.  Zero removes them right away.
+	LinkDeadGrace time.Duration
+
+	Input Input
+
+	// Attributes are the scores every player has, like strength.
+	Attributes game.Attributes
+
+	// Races and Classes are what new players choose from.  Either may be
+	// empty.
+	Races   game.Races
+	Classes game.Classes
+
+	// Clock is where the world gets the time from.  If nil, the system clock
+	// is used.
+	Clock game.Clock
+
+	// Calendar is how game time passes.  If its HourLength is zero,
+	// game.DefaultCalendar is used.
+	Calendar game.Calendar
+
+	// Seed seeds the world's random numbers, so that a run can be reproduced.
+	// Zero picks a seed based on the current time.
+	Seed int64
+
+	// Manual, if true, creates workers that only run when the world is
+	// stepped with Step or Simulate, rather than running on their own.
+	Manual bool
 }
 
-// Init spawns the zones and their attendant workers, creates all areas
-// and locations.
-func Init(cfg Config, datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, wg *sync.WaitGroup) error {
-	if err := loadLocTempl(datadir); err != nil {
-		return err
+// Init creates a world from the data directory.  It spawns the global worker
+// and the zones and their attendant workers, creates all areas and locations,
+// and loads the scripts.  Closing the shutdown channel stops the workers, and
+// waiting on the waitgroup will unblock when they have all exited.
+func Init(cfg Config, datadir string, shutdown <-chan struct{}, wg *sync.WaitGroup) (*World, error) {
+	w := newWorld()
+	var err error
+	if w.locTemplate, err = loadTemplate(datadir, "location.template"); err != nil {
+		return nil, err
+	}
+	if w.scoreTemplate, err = loadOptionalTemplate(datadir, "score.template", defaultScoreTemplate); err != nil {
+		return nil, err
+	}
+	if err := w.initAttributes(cfg.Attributes); err != nil {
+		return nil, err
 	}
 
-	chatMode = cfg.ChatMode
+	if cfg.Clock != nil {
+		w.clock = cfg.Clock
+	}
+	w.started = w.clock.Now()
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	w.rng = game.NewRand(seed)
+
+	w.chatMode = cfg.ChatMode
+	w.linkDeadGrace = cfg.LinkDeadGrace
+	w.inputCfg = cfg.Input
 
 	// ensure that require or deny have the corresponding on or off default
 	switch cfg.ChatMode.Mode {
 	case ChatModeRequire:
-		chatMode.Default = true
+		w.chatMode.Default = true
 	case ChatModeDeny:
-		chatMode.Default = false
+		w.chatMode.Default = false
 	default:
 		// whatever the config set is fine.
 	}
-	initCommands(cfg.Commands)
-	return loadWorld(datadir, zoneLock, shutdown, wg)
+	w.initCommands(cfg.Commands)
+	spawn := func(name string, runLock sync.Locker) *game.Worker {
+		if cfg.Manual {
+			return game.NewManualWorker(name, runLock, w.clock)
+		}
+		return game.SpawnWorker(name, runLock, w.clock, shutdown, wg)
+	}
+	if err := w.loadWorld(datadir, func(name string) *game.Worker {
+		return spawn(name, w.runLock.RLocker())
+	}); err != nil {
+		return nil, err
+	}
+	if err := w.setStart(util.ID(cfg.StartRoom)); err != nil {
+		return nil, err
+	}
+	if err := w.initRaces(cfg.Races, cfg.Classes); err != nil {
+		return nil, err
+	}
+	if err := w.initIdle(cfg.Idle); err != nil {
+		return nil, err
+	}
+	if err := w.initTime(cfg.Calendar); err != nil {
+		return nil, err
+	}
+	w.initActions(filepath.Join(datadir, "scripts"))
+
+	w.global = spawn("global", w.runLock)
+	w.watchIdle()
+	w.watchTime()
+	w.watchCommands()
+	return w, nil
 }
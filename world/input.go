@@ -0,0 +1,185 @@
+package world
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+)
+
+// ErrSpam is returned when a player is disconnected for typing too fast.
+var ErrSpam = errors.New("Player disconnected for spamming")
+
+// Input limits how fast players can enter commands.
+type Input struct {
+	QueueSize       int // how many commands can wait to be run
+	CommandsPerTick int // how many commands a player can run per tick
+	SpamLimit       int // how many commands can be dropped before the player is disconnected
+}
+
+// cmdQueue holds the commands a player has typed that haven't been run yet.
+type cmdQueue struct {
+	lines   chan string
+	dropped int    // commands dropped since the queue was last empty, only used by the reader
+	text    string // the last line taken by Scan, only used by the runner
+}
+
+func (w *World) newCmdQueue() *cmdQueue {
+	size := w.inputCfg.QueueSize
+	if size < 1 {
+		size = 1
+	}
+	return &cmdQueue{lines: make(chan string, size)}
+}
+
+// readLoop reads commands from the connection and puts them on its queue,
+// until the connection closes or the player spams too much.
+func (p *Player) readLoop(c *conn) error {
+	user, q := c.user, c.queue
+	defer close(q.lines)
+	for user.Scan() {
+		// The user entered a command, so by definition has hit enter.
+		atomic.StoreInt32(&p.needsLF, 0)
+		p.touch()
+		if t, ok := user.WriteScanner.(interface{ Truncated() bool }); ok && t.Truncated() {
+			p.WriteString("That line was too long, the end of it was cut off.\n")
+		}
+		line := user.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && p.world.clearNames[strings.ToLower(fields[0])] {
+			q.clear(p)
+			p.reprompt()
+			continue
+		}
+		if err := q.push(p, line); err != nil {
+			return err
+		}
+	}
+	return user.Err()
+}
+
+// push adds the line to the queue.  If the queue is full, the line is dropped
+// and the player is warned, or disconnected if they keep going.
+func (q *cmdQueue) push(p *Player, line string) error {
+	select {
+	case q.lines <- line:
+		if len(q.lines) == 1 {
+			// the queue had emptied out, so the player has slowed down.
+			q.dropped = 0
+		}
+		return nil
+	default:
+	}
+	q.dropped++
+	limit := p.world.inputCfg.SpamLimit
+	if limit > 0 && q.dropped >= limit {
+		logger.Info("disconnecting player for spamming", logging.Player(p.Name()), logging.Event("spam"))
+		q.drain()
+		p.WriteString("\nYou have been disconnected for spamming.\n")
+		return ErrSpam
+	}
+	if q.dropped == 1 {
+		p.WriteString("\nYou are typing too fast!  Your commands are being ignored.  Type clear to stop everything you've typed.\n")
+	}
+	return nil
+}
+
+// clear throws away all the commands waiting in the queue.
+func (q *cmdQueue) clear(p *Player) {
+	p.Printf("Cleared %d waiting commands.\n", q.drain())
+}
+
+// drain empties the queue, returning how many commands were in it.
+func (q *cmdQueue) drain() int {
+	for n := 0; ; n++ {
+		select {
+		case <-q.lines:
+		default:
+			return n
+		}
+	}
+}
+
+// runQueue runs the commands in the connection's queue until the queue is
+// closed or the player exits.
+func (p *Player) runQueue(c *conn) {
+	q := c.queue
+	clock := p.world.clock
+	perTick := p.world.inputCfg.CommandsPerTick
+	tick := clock.Now()
+	n := 0
+	for line := range q.lines {
+		p.waitLag()
+		if perTick > 0 && n >= perTick {
+			clock.Sleep(tick.Add(game.TickLen).Sub(clock.Now()))
+		}
+		if now := clock.Now(); now.Sub(tick) >= game.TickLen {
+			tick = now
+			n = 0
+		}
+		n++
+		q.text = line
+		p.handleCmd(line)
+		if atomic.LoadInt32(&c.exiting) == 1 {
+			// closing the connection stops the read loop.
+			c.user.Close()
+			return
+		}
+	}
+}
+
+// Lag keeps the player from running another command until the given amount of
+// time has passed.  Commands typed in the meantime wait in the queue.  It is
+// safe to call from any goroutine.
+func (p *Player) Lag(d time.Duration) {
+	until := p.world.clock.Now().Add(d).UnixNano()
+	for {
+		old := atomic.LoadInt64(&p.lagUntil)
+		if old >= until || atomic.CompareAndSwapInt64(&p.lagUntil, old, until) {
+			return
+		}
+	}
+}
+
+// waitLag waits until any lag imposed on the player is over.
+func (p *Player) waitLag() {
+	until := time.Unix(0, atomic.LoadInt64(&p.lagUntil))
+	if d := until.Sub(p.world.clock.Now()); d > 0 {
+		p.world.clock.Sleep(d)
+	}
+}
+
+// Scan implements util.Scanner by taking the next command from the queue, so
+// that commands can ask the player questions.  It must only be called while
+// running a command, when the player's conn can't change.
+func (p *Player) Scan() bool {
+	q := p.conn.queue
+	line, ok := <-q.lines
+	q.text = line
+	return ok
+}
+
+// Text implements util.Scanner.
+func (p *Player) Text() string {
+	return p.conn.queue.text
+}
+
+// Bytes implements util.Scanner.
+func (p *Player) Bytes() []byte {
+	return []byte(p.conn.queue.text)
+}
+
+// Err implements util.Scanner.  Errors reading from the connection are handled
+// by the read loop, so there's never an error here.
+func (p *Player) Err() error {
+	return nil
+}
+
+// clearCmd is normally handled by the read loop as soon as it's typed, so it
+// can skip ahead of the commands in the queue.
+func clearCmd(c *Command) {
+	c.Actor.conn.queue.clear(c.Actor)
+	c.Actor.reprompt()
+}
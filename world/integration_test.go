@@ -0,0 +1,429 @@
+package world
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/util"
+)
+
+func TestMovement(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	bob.Send("north")
+	bob.Expect("Windy Alley")
+	bob.Send("s")
+	bob.Expect("Town Square")
+	bob.Expect("Alice is standing here.")
+
+	// a different zone, so bob is handed off to zone 2's worker.
+	bob.Send("east")
+	bob.Expect("Forest Edge")
+	bob.Send("east")
+	bob.Expect("That area is closed.")
+
+	// admins can go into closed areas.
+	alice.Send("east")
+	alice.Expect("Forest Edge")
+	alice.Expect("Bob is standing here.")
+	alice.Send("east")
+	alice.Expect("Locked Vault")
+
+	bob.Send("look")
+	bob.Expect("Forest Edge")
+	bob.Send("look fountain")
+	bob.Expect("You don't see that here.")
+	// the look waits for bob to arrive back in zone 1.
+	bob.Send("west")
+	bob.Send("look fountain")
+	bob.Expect("The water is cold and clear.")
+}
+
+func TestChatMode(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	bob.Send("hello")
+	bob.Expect(`"hello" is not a valid command.`)
+
+	bob.Send("chatmode")
+	bob.Expect("chat mode is now on")
+	bob.Send("hello there")
+	bob.Expect("Bob: hello there")
+	alice.Expect("Bob: hello there")
+
+	// commands need the prefix, but directions on their own still work.
+	bob.Send("/smile")
+	bob.Expect("You smile.")
+	alice.Expect("Bob smiles.")
+	bob.Send("north")
+	bob.Expect("Windy Alley")
+
+	bob.Send("/chatmode ?")
+	bob.Expect("chat mode is on")
+	bob.Send("/chatmode")
+	bob.Expect("chat mode is now off")
+	bob.Send("say bye")
+	bob.Expect("Bob: bye")
+}
+
+func TestSocials(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+	alice.Expect("Bob arrives in a puff of smoke.")
+
+	bob.Send("smile")
+	bob.Expect("You smile.")
+	alice.Expect("Bob smiles.")
+
+	bob.Send("smile alice")
+	bob.Expect("You smile at Alice.")
+	alice.Expect("Bob smiles at you.")
+
+	bob.Send("smile bob")
+	bob.Expect("You smile to yourself.")
+	alice.Expect("Bob smiles to himself.")
+
+	bob.Send("frown")
+	bob.Expect(`"frown" is not a valid command.`)
+}
+
+func TestTell(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	// tells reach players in other zones.
+	alice.Send("east")
+	alice.Expect("Forest Edge")
+	bob.Send("tell alice meet me at the fountain")
+	bob.Expect("You tell Alice: meet me at the fountain")
+	alice.Expect("Bob tells you: meet me at the fountain")
+
+	alice.Send("t bob on my way")
+	alice.Expect("You tell Bob: on my way")
+	bob.Expect("Alice tells you: on my way")
+
+	bob.Send("tell carol hi")
+	bob.Expect("No one with that name exists.")
+}
+
+func TestGoto(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	alice.Send("goto 200")
+	alice.Expect("Forest Edge")
+	alice.Send("goto 999")
+	alice.Expect("There is no room with that number.")
+
+	bob.Send("north")
+	bob.Expect("Windy Alley")
+	alice.Send("goto bob")
+	alice.Expect("Windy Alley")
+	alice.Expect("Bob is standing here.")
+	alice.Send("goto carol")
+	alice.Expect("There is no player with that name.")
+}
+
+func TestScripts(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	alice.Send("north")
+	alice.Expect("Windy Alley")
+	bob.Send("north")
+	bob.Expect("Windy Alley")
+
+	bob.Send("push button")
+	bob.Expect("A cold breeze whistles through the room.")
+	alice.Expect("A cold breeze whistles through the room.")
+}
+
+func TestEvents(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	got := make(chan string, 100)
+	bus := h.w.Events()
+	game.Subscribe(bus, nil, func(e LoggedIn) { got <- "in " + e.Player.Name() })
+	game.Subscribe(bus, nil, func(e Moved) {
+		got <- fmt.Sprintf("%s moved %v-%v", e.Player.Name(), e.From.ID, e.To.ID)
+	})
+	game.Subscribe(bus, nil, func(e Said) { got <- e.Player.Name() + " said " + e.Msg })
+	game.Subscribe(bus, nil, func(e Told) { got <- e.From.Name() + " told " + e.To.Name() + " " + e.Msg })
+	game.Subscribe(bus, nil, func(e LoggedOut) { got <- "out " + e.Player.Name() })
+
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+	bob.Send("east")
+	bob.Expect("Forest Edge")
+	bob.Send("say hi")
+	bob.Expect("Bob: hi")
+	alice.Send("tell bob psst")
+	alice.Expect("You tell Bob: psst")
+	bob.Send("quit")
+	bob.Expect("Are you sure you want to quit?")
+	bob.Send("y")
+
+	expected := []string{
+		"in Alice",
+		"in Bob",
+		"Bob moved 100-200",
+		"Bob said hi",
+		"Alice told Bob psst",
+		"out Bob",
+	}
+	for _, e := range expected {
+		select {
+		case s := <-got:
+			if s != e {
+				t.Fatalf("expected event %q, got %q", e, s)
+			}
+		case <-time.After(expectTimeout):
+			t.Fatalf("timed out waiting for event %q", e)
+		}
+	}
+}
+
+func TestTimeAndWeather(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+	bob.Send("west")
+	bob.Expect("Town Hall")
+
+	alice.Send("time")
+	alice.Expect("It is " + h.w.Time().String())
+	// the weather always starts out clear.
+	alice.Send("weather")
+	alice.Expect("clear")
+	alice.Send("gaze into fountain")
+	alice.Expect("The clear sky is reflected in the water.")
+
+	cal := h.w.calendar
+	hours := (cal.Sunrise - h.w.Time().Hour + cal.HoursPerDay) % cal.HoursPerDay
+	if hours == 0 {
+		hours = cal.HoursPerDay
+	}
+	h.passHours(hours)
+	alice.Expect("The sun rises in the east.")
+	bob.Send("weather")
+	if out := bob.Expect("You can't see the sky from here."); strings.Contains(out, "The sun rises") {
+		t.Errorf("Bob saw the sun rise indoors:\n%s", out)
+	}
+	alice.Send("time")
+	alice.Expect(fmt.Sprintf("It is %d o'clock", cal.Sunrise))
+}
+
+func TestDebugSnapshot(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	h.connect("Alice")
+	bob := h.connect("Bob")
+	bob.Send("east")
+	bob.Expect("Forest Edge")
+
+	players := map[util.ID]int{}
+	for _, z := range h.w.Zones() {
+		players[z.ID] = z.Players
+		if z.ID == 3 && !z.Closed {
+			t.Error("expected zone 3 to be closed")
+		}
+	}
+	if players[1] != 1 || players[2] != 1 || players[3] != 0 {
+		t.Errorf("expected one player each in zones 1 and 2, got %v", players)
+	}
+
+	sessions, err := h.w.Sessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	if s := sessions[1]; s.Player != "Bob" || s.Username != "bob" || s.Room != 200 || s.Zone != 2 {
+		t.Errorf("unexpected session for bob: %+v", s)
+	}
+	if s := sessions[0]; s.Addr != "127.0.0.1:40001" || s.LinkDead {
+		t.Errorf("unexpected session for alice: %+v", s)
+	}
+}
+
+func TestDebugSnapshotStuckWorld(t *testing.T) {
+	// a manual world's global worker only runs when it's stepped, so it looks
+	// stuck.
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.Manual = true
+	})
+	defer h.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := h.w.Sessions(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the stuck world to time out, got %v", err)
+	}
+	if zones := h.w.Zones(); len(zones) == 0 {
+		t.Error("expected zones even though the world is stuck")
+	}
+
+	// once the worker runs again, so do sessions.
+	type result struct {
+		sessions []SessionInfo
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		sessions, err := h.w.Sessions(context.Background())
+		done <- result{sessions, err}
+	}()
+	deadline := time.Now().Add(expectTimeout)
+	for {
+		h.w.global.Step()
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sessions never answered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScore(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+
+	dbp, err := h.st.FindPlayer("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := dbp.Attributes["STR"]
+	if str < 3 || str > 18 {
+		t.Errorf("expected a saved STR between 3 and 18, got %d", str)
+	}
+	if luck := dbp.Attributes["LUCK"]; luck != 5 {
+		t.Errorf("expected a saved LUCK of 5, got %d", luck)
+	}
+
+	alice.Send("score")
+	alice.Expect("Alice, male")
+	alice.Expect("Race:  Human")
+	alice.Expect("Class: Warrior")
+	alice.Expect("[Attributes]")
+	alice.Expect(fmt.Sprintf("Strength       %3d", str))
+	alice.Expect("LUCK             5")
+}
+
+func TestDefaultScoreTemplate(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	h.connect("Alice")
+	p, ok := h.w.players.find("Alice")
+	if !ok {
+		t.Fatal("can't find Alice")
+	}
+
+	// data directories made before score.template existed get the default,
+	// which should show the same as the one that ships with the MUD.
+	def, err := loadOptionalTemplate(fixtureDir, "missing.template", defaultScoreTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipped, err := loadTemplate("../data", "score.template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want bytes.Buffer
+	done := make(chan error, 1)
+	p.HandleLocal(func() {
+		if err := def.Execute(&got, p); err != nil {
+			done <- err
+			return
+		}
+		done <- shipped.Execute(&want, p)
+	})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("expected the default score to match data/score.template's:\n%s\ngot:\n%s", want.String(), got.String())
+	}
+}
+
+func TestRaceAndClass(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+
+	bob := h.create("Bob", "2", "2")
+	bob.Expect("Forest Edge")
+	bob.Expect(">")
+
+	dbp, err := h.st.FindPlayer("Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbp.Race != "Elf" || dbp.Class != "Mage" {
+		t.Errorf("expected a saved elf mage, got %q %q", dbp.Race, dbp.Class)
+	}
+	if luck := dbp.Attributes["LUCK"]; luck != 8 {
+		t.Errorf("expected LUCK of 5 plus the race and class modifiers, got %d", luck)
+	}
+	if dbp.Flags.Bit(int(PFlagChatmode)) != 1 {
+		t.Error("expected the mage to start in chat mode")
+	}
+
+	bob.Send("/score")
+	bob.Expect("Race:  Elf")
+	bob.Expect("Class: Mage")
+
+	alice.Send("who")
+	alice.Expect("Alice (Human Warrior)")
+	alice.Expect("Bob (Elf Mage)")
+}
+
+func TestSeparateWorlds(t *testing.T) {
+	h1 := newHarness(t)
+	defer h1.close()
+	h1.connect("Alice")
+	h1.connect("Bob")
+
+	// a second world in the same process knows nothing about the first.
+	h2 := newHarness(t)
+	defer h2.close()
+	if n := h2.w.Sent().Total(); n != 0 {
+		t.Errorf("expected nothing sent in the new world, got %d bytes", n)
+	}
+	if h1.w.Sent().Total() == 0 {
+		t.Error("expected bytes sent in the first world to be counted")
+	}
+
+	// Bob is only logged in to the first world, and that's the one that asks.
+	bob := h1.login("Bob")
+	bob.Expect("This account is already logged in.")
+	bob.Send("c")
+}
@@ -0,0 +1,64 @@
+package world
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+)
+
+// LinkDead reports whether the player has lost their connection and is waiting
+// for the user to reconnect.  It is safe to call from any goroutine.
+func (p *Player) LinkDead() bool {
+	return atomic.LoadInt32(&p.linkdead) == 1
+}
+
+// loseLink is called when the user's connection drops without the player
+// quitting.  The player stays where they are, marked link-dead, until the user
+// logs back in or the grace period runs out.
+func (p *Player) loseLink(c *conn) {
+	c.user.Close()
+	grace := p.world.linkDeadGrace
+	if grace == 0 {
+		p.leave(c)
+		return
+	}
+	// intentionally directly call the global handler so we skip the autoprompt
+	// here.
+	p.world.global.Handle(func() {
+		if c.replaced {
+			// another connection has already taken over the player.
+			return
+		}
+		atomic.StoreInt32(&p.linkdead, 1)
+		game.Publish(p.world.bus, LinkLost{Player: p, Loc: p.Location()})
+		p.linkdeadTimer = p.world.global.After(grace, func() { p.expireLink(c) })
+		for _, other := range p.Location().Players {
+			if !p.Is(other) {
+				other.Printf("%s has lost their link.", p.Name())
+				other.prompt()
+			}
+		}
+	})
+}
+
+// expireLink removes the player from the world if they are still link-dead
+// from the given connection once the grace period is over.  It must be run on
+// the global worker.
+func (p *Player) expireLink(c *conn) {
+	if !p.LinkDead() || c.replaced {
+		// the user reconnected in the meantime.
+		return
+	}
+	logger.Info("removing link-dead player from world", logging.Player(p.Name()), logging.Event("linkdead"))
+	p.linkdeadTimer = nil
+	for _, other := range p.Location().Players {
+		if !p.Is(other) {
+			io.WriteString(other, p.Name()+" fades away.\n")
+			other.prompt()
+		}
+	}
+	// don't hold up the worker writing to the db.
+	go p.save(p.remove())
+}
@@ -0,0 +1,79 @@
+package world
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkDeadReconnect(t *testing.T) {
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.LinkDeadGrace = time.Hour
+	})
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	bob.conn.Close()
+	alice.Expect("Bob has lost their link.")
+	alice.Send("who")
+	alice.Expect("Bob (Human Warrior) (linkdead)")
+
+	// link-dead players don't count as logged in, so there's no takeover
+	// question.
+	bob = h.login("Bob")
+	bob.Expect("Reconnecting to your character.")
+	bob.Expect("Town Square")
+	alice.Expect("Bob has reconnected.")
+
+	bob.Send("say hi")
+	alice.Expect("Bob: hi")
+}
+
+func TestLinkDeadExpires(t *testing.T) {
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.LinkDeadGrace = 200 * time.Millisecond
+	})
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	bob.conn.Close()
+	alice.Expect("Bob has lost their link.")
+	alice.Expect("Bob fades away.")
+	if _, ok := h.w.players.find("Bob"); ok {
+		t.Error("expected Bob to have left the world")
+	}
+
+	// logging back in starts from the character menu, like any other login.
+	bob = h.login("Bob")
+	bob.Expect("Choose a player")
+	bob.Send("2")
+	bob.Expect("You arrive in a puff of smoke.")
+	alice.Expect("Bob arrives in a puff of smoke.")
+}
+
+func TestTakeover(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	// the old connection is in the middle of a command when it's taken over.
+	bob.Send("quit")
+	bob.Expect("Are you sure you want to quit?")
+
+	bob2 := h.login("Bob")
+	bob2.Expect("This account is already logged in.")
+	bob2.Send("t")
+	bob.Expect("This character has been taken over by another connection.")
+	bob.ExpectClosed()
+	bob2.Expect("You take over your character.")
+	bob2.Expect("Town Square")
+	alice.Expect("Bob's eyes flicker as a new mind takes control.")
+
+	bob2.Send("say still here")
+	alice.Expect("Bob: still here")
+	if _, ok := h.w.players.find("Bob"); !ok {
+		t.Error("expected Bob to still be in the world")
+	}
+}
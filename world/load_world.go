@@ -3,23 +3,18 @@ package world
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 
 	"github.com/natefinch/claymud/game"
 	"github.com/natefinch/claymud/util"
 )
 
-var (
-	allZones = map[util.ID]*Zone{}
-	allMobs  = map[util.ID]*Mob{}
-)
-
-func loadWorld(datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, wg *sync.WaitGroup) error {
-	log.Printf("loading zones from %v", filepath.Join(datadir, "zones"))
+// loadWorld loads the zones, rooms, and mobs from the data directory.  Each
+// zone gets a worker from spawn.
+func (w *World) loadWorld(datadir string, spawn func(name string) *game.Worker) error {
+	logger.Info("loading zones", "dir", filepath.Join(datadir, "zones"))
 	files, err := filepath.Glob(filepath.Join(datadir, "zones", "*.json"))
 	if err != nil {
 		return fmt.Errorf("failed to read zone files: %v", err)
@@ -29,7 +24,7 @@ func loadWorld(datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, w
 		if err != nil {
 			return err
 		}
-		if z, exists := allZones[zone.ID]; exists {
+		if z, exists := w.allZones[zone.ID]; exists {
 			return fmt.Errorf("file %q contains %s which duplicates zone %s", file, zone, z)
 		}
 		zone.Add(
@@ -37,34 +32,36 @@ func loadWorld(datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, w
 				Name:    zone.Name,
 				LocByID: map[util.ID]*Location{},
 			})
-		allZones[zone.ID] = zone
-		zone.Worker = game.SpawnWorker(zoneLock, shutdown, wg)
+		zone.world = w
+		w.allZones[zone.ID] = zone
+		zone.Worker = spawn(fmt.Sprintf("zone %v", zone.ID))
+		zone.OnPanic = zone.panicked
 	}
-	log.Printf("loaded %v zones", len(files))
+	logger.Info("loaded zones", "count", len(files))
 
-	log.Printf("loading rooms from %v", filepath.Join(datadir, "rooms"))
+	logger.Info("loading rooms", "dir", filepath.Join(datadir, "rooms"))
 	files, err = filepath.Glob(filepath.Join(datadir, "rooms", "*.json"))
 	if err != nil {
 		return fmt.Errorf("failed to read room files: %v", err)
 	}
-	log.Printf("found %d room files", len(files))
+	logger.Debug("found room files", "count", len(files))
 	var jsonRooms []jsonRoom
 	count := 0
 	for _, file := range files {
-		jrs, err := decodeRooms(locMap, file)
+		jrs, err := w.decodeRooms(file)
 		if err != nil {
 			return err
 		}
 		count += len(jrs)
 		jsonRooms = append(jsonRooms, jrs...)
 	}
-	log.Printf("loaded %v rooms", count)
+	logger.Info("loaded rooms", "count", count)
 
 	// ok, now that we've loaded all the room definitions, we have to go back
 	// and hook up all the exits. We have to do this afterward because an exit
 	// might refer to a location that hasn't been loaded yet.
 	for _, r := range jsonRooms {
-		loc, exists := locMap[util.ID(r.ID)]
+		loc, exists := w.locMap[util.ID(r.ID)]
 		if !exists {
 			return fmt.Errorf("should be impossible, jsonRoom refers to unknown location %v", r.ID)
 		}
@@ -77,7 +74,7 @@ func loadWorld(datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, w
 			if !exists {
 				return fmt.Errorf("should be impossible, direction %q for exit in room %v doesn't exist", e.Direction, r.ID)
 			}
-			target, exists := locMap[util.ID(e.Destination)]
+			target, exists := w.locMap[util.ID(e.Destination)]
 			if !exists {
 				return fmt.Errorf("direction %q for exit in room %v references non-existant room %v", e.Direction, r.ID, e.Destination)
 			}
@@ -89,21 +86,21 @@ func loadWorld(datadir string, zoneLock sync.Locker, shutdown <-chan struct{}, w
 		}
 	}
 
-	log.Printf("loading mobs from %v", filepath.Join(datadir, "mobs"))
+	logger.Info("loading mobs", "dir", filepath.Join(datadir, "mobs"))
 	files, err = filepath.Glob(filepath.Join(datadir, "mobs", "*.json"))
 	if err != nil {
 		return fmt.Errorf("failed to read mob files: %v", err)
 	}
-	log.Printf("found %d mob files", len(files))
+	logger.Debug("found mob files", "count", len(files))
 	count = 0
 	for _, file := range files {
-		c, err := decodeMobs(file)
+		c, err := w.decodeMobs(file)
 		if err != nil {
 			return err
 		}
 		count += c
 	}
-	log.Printf("loaded %v mobs", count)
+	logger.Info("loaded mobs", "count", count)
 
 	return nil
 }
@@ -123,14 +120,25 @@ func decodeZone(file string) (*Zone, error) {
 	}
 	defer f.Close()
 	d := json.NewDecoder(f)
-	var zone Zone
-	if err := d.Decode(&zone); err != nil {
+	var jz jsonZone
+	if err := d.Decode(&jz); err != nil {
 		return nil, fmt.Errorf("unable to decode zone file %q: %v", file, err)
 	}
-	return &zone, nil
+	zone := &Zone{ID: jz.ID, Name: jz.Name}
+	if jz.Closed {
+		zone.closed = 1
+	}
+	return zone, nil
+}
+
+// jsonZone is the part of a zone file the MUD uses.
+type jsonZone struct {
+	ID     util.ID
+	Name   string
+	Closed bool
 }
 
-func decodeRooms(rooms map[util.ID]*Location, file string) ([]jsonRoom, error) {
+func (w *World) decodeRooms(file string) ([]jsonRoom, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, fmt.Errorf("can't open room file: %v", err)
@@ -144,14 +152,14 @@ func decodeRooms(rooms map[util.ID]*Location, file string) ([]jsonRoom, error) {
 		return nil, fmt.Errorf("unable to decode room file %q: %v", file, err)
 	}
 	for _, r := range decoded.Rooms {
-		if rm, exists := rooms[util.ID(r.ID)]; exists {
+		if rm, exists := w.locMap[util.ID(r.ID)]; exists {
 			return nil, fmt.Errorf("room %v (%s) already exists as %q", r.ID, r.Name, rm.Name)
 		}
-		loc, err := r.toLoc()
+		loc, err := r.toLoc(w.allZones)
 		if err != nil {
 			return nil, err
 		}
-		rooms[loc.ID] = loc
+		w.locMap[loc.ID] = loc
 	}
 	return decoded.Rooms, nil
 }
@@ -174,8 +182,8 @@ type jsonAction struct {
 	IsGlobal bool
 }
 
-func (j jsonRoom) toLoc() (*Location, error) {
-	z, exists := allZones[util.ID(j.Zone)]
+func (j jsonRoom) toLoc(zones map[util.ID]*Zone) (*Location, error) {
+	z, exists := zones[util.ID(j.Zone)]
 	if !exists {
 		return nil, fmt.Errorf("room %v's zone %v does not exist", j.ID, j.Zone)
 	}
@@ -184,6 +192,10 @@ func (j jsonRoom) toLoc() (*Location, error) {
 			return nil, fmt.Errorf("direction %q for exit in room %v does not exist", e.Direction, j.ID)
 		}
 	}
+	sector, err := parseSector(j.Sector)
+	if err != nil {
+		return nil, fmt.Errorf("room %v: %v", j.ID, err)
+	}
 	loc := &Location{
 		ID:           util.ID(j.ID),
 		Name:         j.Name,
@@ -191,6 +203,11 @@ func (j jsonRoom) toLoc() (*Location, error) {
 		Descriptions: map[string]string{},
 		Players:      map[string]*Player{},
 		Actions:      map[string]Action{},
+		Sector:       sector,
+		Flags:        map[string]bool{},
+	}
+	for _, b := range j.Bits {
+		loc.Flags[b] = true
 	}
 	for k, v := range j.Actions {
 		loc.Actions[k] = Action(v)
@@ -283,7 +300,7 @@ func (m jsonMob) ToMob() (*Mob, error) {
 	}, nil
 }
 
-func decodeMobs(file string) (int, error) {
+func (w *World) decodeMobs(file string) (int, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return 0, fmt.Errorf("can't open room file: %v", err)
@@ -297,14 +314,14 @@ func decodeMobs(file string) (int, error) {
 		return 0, fmt.Errorf("unable to decode room file %q: %v", file, err)
 	}
 	for _, m := range decoded.Mobs {
-		if mb, exists := allMobs[util.ID(m.Number)]; exists {
+		if mb, exists := w.allMobs[util.ID(m.Number)]; exists {
 			return 0, fmt.Errorf("mob %v (%s) already exists as %q", m.Number, m.ShortDesc, mb.Name)
 		}
 		mb, err := m.ToMob()
 		if err != nil {
 			return 0, err
 		}
-		allMobs[mb.ID] = mb
+		w.allMobs[mb.ID] = mb
 	}
 	return len(decoded.Mobs), nil
 }
@@ -3,7 +3,7 @@ package world
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
+	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -11,29 +11,6 @@ import (
 	"github.com/natefinch/claymud/util"
 )
 
-var (
-	locMap = map[util.ID]*Location{}
-	start  *Location
-)
-
-// SetStart sets the starting room of the mud.
-func SetStart(room util.ID) error {
-	loc, exists := locMap[room]
-	if !exists {
-		return fmt.Errorf("starting room %v does not exist", room)
-	}
-	start = loc
-	return nil
-}
-
-// Start returns the start room of the MUD, where players appear
-// TODO: multiple / configurable start rooms
-func Start() *Location {
-	return start
-}
-
-var locTemplate *template.Template
-
 // A Location in the mud, such as a room
 type Location struct {
 	ID   util.ID
@@ -43,6 +20,8 @@ type Location struct {
 	Area         *Area
 	Players      map[string]*Player
 	Descriptions map[string]string
+	Sector       Sector
+	Flags        map[string]bool // the room's bits, like INDOORS or DARK
 
 	// LocalActions is a map of command phrases to script names that get run in a zone-local thread.
 	Actions map[string]Action
@@ -97,23 +76,46 @@ func (l *Location) LookTarget(target string) (string, bool) {
 // ShowRoom displays the room description from the point of view of the given
 // actor.
 func (l *Location) ShowRoom(actor *Player) {
-	locTemplate.Execute(actor, locData{actor, l})
+	l.World().locTemplate.Execute(actor, locData{actor, l})
+}
+
+// World returns the world the location is in.
+func (l *Location) World() *World {
+	return l.Area.Zone.world
 }
 
-func loadLocTempl(datadir string) error {
-	path := filepath.Join(datadir, "location.template")
-	log.Printf("Loading location template from %s", path)
+// loadTemplate loads and parses the named template from the data directory.
+func loadTemplate(datadir, name string) (*template.Template, error) {
+	path := filepath.Join(datadir, name)
+	logger.Info("loading template", "file", path)
 
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("error reading location template file: %s", err)
+		return nil, fmt.Errorf("error reading template file: %s", err)
 	}
+	return parseTemplate(name, string(b))
+}
+
+// loadOptionalTemplate is like loadTemplate, but uses the default text if the
+// file doesn't exist, so data directories made before the template was added
+// still work.
+func loadOptionalTemplate(datadir, name, def string) (*template.Template, error) {
+	path := filepath.Join(datadir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		logger.Info("template file not found, using the default", "file", path)
+		return parseTemplate(name, def)
+	}
+	return loadTemplate(datadir, name)
+}
 
-	locTemplate, err = template.New("location.template").Parse(string(b))
+// parseTemplate parses a template with the functions templates can use.
+func parseTemplate(name, text string) (*template.Template, error) {
+	funcs := template.FuncMap{"wrap": util.Wrap}
+	t, err := template.New(name).Funcs(funcs).Parse(text)
 	if err != nil {
-		return fmt.Errorf("can't parse location template: %s", err)
+		return nil, fmt.Errorf("can't parse %s: %s", name, err)
 	}
-	return nil
+	return t, nil
 }
 
 type locData struct {
@@ -0,0 +1,88 @@
+package world
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/util"
+)
+
+// CommandsRun returns the number of commands players have typed since the world
+// started.
+func (w *World) CommandsRun() int64 {
+	return atomic.LoadInt64(&w.commandsRun)
+}
+
+// Sent counts the bytes of output sent to the world's players.  The
+// connections of players logging in to the world should add to it.
+func (w *World) Sent() *util.Counter {
+	return &w.sent
+}
+
+// CommandRate returns the number of commands players typed in the last second.
+func (w *World) CommandRate() int64 {
+	return atomic.LoadInt64(&w.commandRate)
+}
+
+// watchCommands keeps track of how many commands are run each second.
+func (w *World) watchCommands() {
+	last := w.CommandsRun()
+	w.global.Every(time.Second, func() {
+		n := w.CommandsRun()
+		atomic.StoreInt64(&w.commandRate, n-last)
+		last = n
+	})
+}
+
+// WorkerStats returns the stats for the global worker followed by each zone's
+// worker, ordered by zone ID.  It is safe to call from any goroutine.
+func (w *World) WorkerStats() []game.WorkerStats {
+	zones := w.sortedZones()
+	stats := make([]game.WorkerStats, 0, len(zones)+1)
+	stats = append(stats, w.global.Stats())
+	for _, z := range zones {
+		stats = append(stats, z.Stats())
+	}
+	return stats
+}
+
+// lag shows admins how busy the workers are.  Zones that haven't handled any
+// events are left out unless the admin types "lag all".
+func lag(c *Command) {
+	if !c.requireAdmin() {
+		return
+	}
+	all := strings.EqualFold(c.Target(), "all")
+	workers := c.World.WorkerStats()
+	// the global worker is always shown.
+	stats := []game.WorkerStats{workers[0]}
+	for _, s := range workers[1:] {
+		if all || s.Events > 0 {
+			stats = append(stats, s)
+		}
+	}
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Worker\tEvents\tLast\tAvg tick\tMax tick\tOverruns\tAvg wait\tMax wait\tErrors")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\t%v\t%d\t%v\t%v\t%d\n",
+			s.Name, s.Events, s.LastTickEvents, ms(s.AvgTick()), ms(s.MaxTick),
+			s.Overruns, ms(s.AvgQueueWait()), ms(s.MaxQueueWait), s.Panics)
+	}
+	w.Flush()
+	st := c.World.Status()
+	fmt.Fprintf(buf, "\nPlayers: %d  Commands/sec: %d  Bytes sent: %d\n", st.Players, c.World.CommandRate(), c.World.sent.Total())
+	c.Actor.HandleLocal(func() {
+		c.Actor.WriteString(buf.String())
+	})
+}
+
+// ms formats the duration in milliseconds.
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,199 @@
+package world
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// busyClock is a clock whose ticks don't wait, so benchmarks measure the work
+// the workers do rather than how long a tick lasts.
+type busyClock struct{}
+
+func (busyClock) Now() time.Time      { return time.Now() }
+func (busyClock) Sleep(time.Duration) { runtime.Gosched() }
+
+// moveBenchPlayers is how many players walk between zones at once.
+const moveBenchPlayers = 8
+
+// BenchmarkCrossZoneMove walks players back and forth between zones 1 and 2,
+// once by handing them from one zone's worker to the other's, and once the old
+// way, by relocating them on the global worker.  Each op is one move.
+//
+// The stall metric is how long, on average, an event in zone 3 waited to be
+// handled while the players moved.  Zone 3 has nothing to do with the moves,
+// so that time is spent waiting on the global worker.
+func BenchmarkCrossZoneMove(b *testing.B) {
+	b.Run("handoff", func(b *testing.B) {
+		benchmarkMove(b, func(p *Player, to *Location) {
+			p.Move(to)
+			p.settle()
+		})
+	})
+	b.Run("global", func(b *testing.B) {
+		benchmarkMove(b, func(p *Player, to *Location) {
+			done := make(chan struct{})
+			p.HandleGlobal(func() {
+				p.Relocate(to)
+				close(done)
+			})
+			<-done
+		})
+	})
+}
+
+func benchmarkMove(b *testing.B, move func(p *Player, to *Location)) {
+	h := newHarnessWith(b, func(cfg *Config) {
+		cfg.Clock = busyClock{}
+	})
+	defer h.close()
+	square, _ := h.w.Location(100)
+	forest, _ := h.w.Location(200)
+	type mover struct {
+		p *Player
+		c *client
+	}
+	movers := make([]mover, moveBenchPlayers)
+	for i := range movers {
+		name := fmt.Sprintf("Mover%c", 'a'+i)
+		c := h.connect(name)
+		p, ok := h.w.FindPlayer(name)
+		if !ok {
+			b.Fatalf("%s isn't in the world", name)
+		}
+		movers[i] = mover{p: p, c: c}
+	}
+
+	stop := make(chan struct{})
+	stalls := make(chan time.Duration)
+	go func() {
+		// count how long zone 3's events wait while the players move.
+		var total time.Duration
+		var n int64
+		z := h.w.allZones[3]
+		for {
+			select {
+			case <-stop:
+				if n > 0 {
+					total /= time.Duration(n)
+				}
+				stalls <- total
+				return
+			default:
+			}
+			start := time.Now()
+			z.Handle(func() {})
+			total += time.Since(start)
+			n++
+		}
+	}()
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i, m := range movers {
+		// spread the moves between the players.
+		n := b.N / len(movers)
+		if i < b.N%len(movers) {
+			n++
+		}
+		wg.Add(1)
+		go func(m mover, n int) {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				to, name := forest, "Forest Edge"
+				if j%2 == 1 {
+					to, name = square, "Town Square"
+				}
+				move(m.p, to)
+				m.c.Expect(name)
+			}
+		}(m, n)
+	}
+	wg.Wait()
+	b.StopTimer()
+	close(stop)
+	b.ReportMetric(float64(<-stalls), "stall-ns")
+}
+
+// TestHandoffInterrupted has the global worker move or remove a player between
+// the two steps of a move to another zone, and checks that the second step
+// leaves them alone.
+func TestHandoffInterrupted(t *testing.T) {
+	tests := []struct {
+		name      string
+		interrupt func(h *harness, p *Player)
+		check     func(t *testing.T, h *harness, p *Player, bob *client)
+	}{{
+		name: "void",
+		interrupt: func(h *harness, p *Player) {
+			hall, _ := h.w.Location(102)
+			p.Relocate(hall)
+		},
+		check: func(t *testing.T, h *harness, p *Player, bob *client) {
+			bob.Expect("Town Hall")
+			if loc := p.Location(); loc.ID != 102 {
+				t.Errorf("expected Bob to stay in the town hall, but Bob is in %v", loc.ID)
+			}
+		},
+	}, {
+		name: "remove",
+		interrupt: func(h *harness, p *Player) {
+			p.remove()
+		},
+		check: func(t *testing.T, h *harness, p *Player, bob *client) {
+			if _, ok := h.w.players.find("Bob"); ok {
+				t.Error("expected Bob to stay out of the world")
+			}
+		},
+	}}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			h := newHarnessWith(t, func(cfg *Config) {
+				cfg.Manual = true
+			})
+			// step the world until the test takes over, and after it's done.
+			var stepMu sync.Mutex
+			defer h.keepStepping(&stepMu)()
+			defer h.close()
+
+			h.connect("Alice")
+			bob := h.connect("Bob")
+			p, ok := h.w.players.find("Bob")
+			if !ok {
+				t.Fatal("can't find Bob")
+			}
+			square := h.w.allZones[1]
+			forest, _ := h.w.Location(200)
+
+			stepMu.Lock()
+			bob.Send("east")
+			deadline := time.Now().Add(expectTimeout)
+			for square.Queued() == 0 {
+				if time.Now().After(deadline) {
+					stepMu.Unlock()
+					t.Fatal("Bob's move never started")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			// the first step takes Bob out of the square.
+			square.Step()
+			if p.Location() != forest {
+				stepMu.Unlock()
+				t.Fatalf("expected Bob to be on the way to the forest, but Bob is headed to %v", p.Location().ID)
+			}
+			h.w.global.Handle(func() { test.interrupt(h, p) })
+			h.w.global.Step()
+			// the second step would put Bob in the forest.
+			forest.Area.Zone.Step()
+			stepMu.Unlock()
+
+			if forest.Target("bob") != nil {
+				t.Error("expected Bob not to arrive in the forest")
+			}
+			test.check(t, h, p, bob)
+		})
+	}
+}
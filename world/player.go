@@ -4,10 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math/big"
-	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 
@@ -18,6 +18,7 @@ import (
 	"github.com/natefinch/claymud/game/social"
 
 	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
 	"github.com/natefinch/claymud/util"
 )
 
@@ -38,49 +39,50 @@ const (
 	PFlagChatmode PFlag = iota
 )
 
-var (
-	playerMap  = map[string]*Player{}
-	playerList = &sortedPlayers{}
-	userMap    = map[string]*auth.User{}
-)
-
-type sortedPlayers []*Player
+// pflagNames are the names of the player flags, as used in config files.
+var pflagNames = map[string]PFlag{
+	"chatmode": PFlagChatmode,
+}
 
-func (s *sortedPlayers) add(p *Player) {
-	*s = append(*s, p)
-	sort.SliceStable(*s, func(i, j int) bool { return (*s)[i].Name() < (*s)[j].Name() })
+// addPlayer adds a new player to the world list.  It must be run on the global
+// worker.
+func (w *World) addPlayer(p *Player) {
+	w.players.add(p)
 }
-func (s *sortedPlayers) remove(p *Player) {
-	for i, pl := range *s {
-		if pl.Is(p) {
-			*s = append((*s)[:i], (*s)[i+1:]...)
-			break
-		}
-	}
+
+// removePlayer removes a player from the world list.  It must be run on the
+// global worker.
+func (w *World) removePlayer(p *Player) {
+	w.players.remove(p)
 }
 
-// addPlayer adds a new player to the world list.
-func addPlayer(p *Player) {
-	playerMap[p.Name()] = p
-	playerList.add(p)
+// FindPlayer returns the player for the given name.  This is a
+// case-insensitive check.  It is safe to call from any goroutine.
+func (w *World) FindPlayer(name string) (*Player, bool) {
+	return w.players.find(name)
 }
 
-// removePlayer removes a player from the world list.
-func removePlayer(p *Player) {
-	delete(playerMap, p.Name())
-	playerList.remove(p)
+// FindUser returns the user for the given username, if that user has a player
+// in the world.  It is safe to call from any goroutine.
+func (w *World) FindUser(name string) (*auth.User, bool) {
+	p, ok := w.userPlayer(name)
+	if !ok {
+		return nil, false
+	}
+	return p.User, true
 }
 
-// FindPlayer returns the player for the given name.
-func FindPlayer(name string) (*Player, bool) {
-	p, ok := playerMap[name]
-	return p, ok
+// Active reports whether the user has a connected player in the world.
+// Link-dead players don't count, since nobody is using them.  It is safe to call
+// from any goroutine.
+func (w *World) Active(username string) bool {
+	p, ok := w.userPlayer(username)
+	return ok && !p.LinkDead()
 }
 
-// FindUser returns the user for the given username.
-func FindUser(name string) (*auth.User, bool) {
-	p, ok := userMap[name]
-	return p, ok
+// userPlayer returns the player the user is currently playing.
+func (w *World) userPlayer(username string) (*Player, bool) {
+	return w.players.user(username)
 }
 
 // Player represents a player-character in the world.
@@ -89,69 +91,269 @@ type Player struct {
 	uuid   uuid.UUID
 	name   string
 	Desc   string
-	loc    *Location
 	gender game.Gender
-	global *game.Worker
+	world  *World
+	st     *db.Store
 	*auth.User
 	util.SafeWriter
 	bits    *big.Int
-	needsLF bool
-	exiting bool
+	attrs   map[string]int // keyed by attribute name
+	race    string
+	class   string
+	needsLF int32 // 1 if output should start on a new line, accessed atomically
+
+	// conn is the user's connection to the player.  It is only changed on the
+	// global worker, and only once the old conn's goroutines have finished.
+	conn *conn
+
+	lastInput int64     // time of the last input in unix nanoseconds, accessed atomically
+	idle      int32     // the player's idleState, accessed atomically
+	voidFrom  *Location // where the player was before being moved to the void
+
+	linkdead      int32       // 1 if the player lost their connection, accessed atomically
+	linkdeadTimer *game.Timer // removes a link-dead player when the grace period ends
+
+	lagUntil int64 // no commands run until this time in unix nanoseconds, accessed atomically
+
+	// loc is changed by the worker for the zone the player is in, but read from
+	// anywhere, such as to find which worker handles the player's commands.
+	loc  atomic.Pointer[Location]
+	gone int32 // 1 once the player has been removed from the world, accessed atomically
 }
 
 // SpawnPlayer attaches the connection to a player and inserts it into the world.  This
 // function runs for as long as the player is in the world.
-func SpawnPlayer(st *db.Store, user *auth.User, global *game.Worker) error {
-	dbp, err := chooseDBPlayer(st, user)
+func (w *World) SpawnPlayer(st *db.Store, user *auth.User) error {
+	if p, ok := w.userPlayer(user.Username); ok {
+		// the user chose to take over their existing session.
+		if c := p.reattach(user); c != nil {
+			return p.run(c)
+		}
+		// the player left the world in the meantime, so start over.
+	}
+	dbp, err := w.chooseDBPlayer(st, user)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Spawning user %s's player %s with id: %v", user.Username, dbp.Name, dbp.ID)
+	logger.Info("spawning player", logging.User(user.Username), logging.Player(dbp.Name), "id", dbp.ID, logging.Event("login"))
+
+	p := w.newPlayer(st, user, dbp)
+	start := p.startRoom()
+	p.enter(start, func(others io.Writer) {
+		social.DoArrival(p, start.setting(), others)
+	})
+	return p.run(p.conn)
+}
+
+// RestorePlayer puts a user's player back into the room it was in before the
+// MUD rebooted.  Like SpawnPlayer, it runs for as long as the player is in the
+// world.
+func (w *World) RestorePlayer(st *db.Store, user *auth.User, name string, room util.ID) error {
+	dbp, err := st.FindPlayer(name)
+	if err != nil {
+		return err
+	}
+	loc, ok := w.Location(room)
+	if !ok {
+		loc = w.Start()
+	}
+	logger.Info("restoring player", logging.User(user.Username), logging.Player(dbp.Name), logging.Room(loc.ID), logging.Event("login"))
 
-	loc := Start()
+	p := w.newPlayer(st, user, dbp)
+	p.enter(loc, func(others io.Writer) {
+		fmt.Fprintf(others, "%s blinks back into existence.\n", p.Name())
+	})
+	return p.run(p.conn)
+}
+
+// newPlayer creates a player for the user from the player's data in the db.
+func (w *World) newPlayer(st *db.Store, user *auth.User, dbp *db.Player) *Player {
 	p := &Player{
 		name:    dbp.Name,
 		Desc:    dbp.Description,
 		ID:      dbp.ID,
-		loc:     loc,
 		gender:  dbp.Gender,
-		global:  global,
-		User:    user,
-		needsLF: true,
+		world:   w,
+		st:      st,
+		needsLF: 1,
 		bits:    dbp.Flags,
+		attrs:   w.playerAttrs(dbp.Attributes),
+		race:    dbp.Race,
+		class:   dbp.Class,
+
+		lastInput: w.clock.Now().UnixNano(),
 	}
-	p.SafeWriter = util.SafeWriter{Writer: user, OnErr: p.exit}
+	p.attach(user)
+	return p
+}
 
+// enter adds the player to the world at the given location.  The arrive
+// function is called to tell the others in the room that the player arrived.
+func (p *Player) enter(loc *Location, arrive func(others io.Writer)) {
+	p.loc.Store(loc)
 	// intentionally directly call the global handler so we skip the autoprompt
 	// here.
-	p.global.Handle(func() {
-		addPlayer(p)
+	p.world.global.Handle(func() {
+		p.world.addPlayer(p)
+		game.Publish(p.world.bus, LoggedIn{Player: p, Loc: loc})
 	})
 	p.HandleLocal(func() {
 		loc.AddPlayer(p)
+		game.Publish(p.world.bus, PlayerEntered{Player: p, Loc: loc})
 		others := make([]io.Writer, 0, len(loc.Players))
 		for _, other := range loc.Players {
 			if !p.Is(other) {
 				others = append(others, other)
 			}
 		}
-		social.DoArrival(p, io.MultiWriter(others...))
+		arrive(io.MultiWriter(others...))
 		loc.ShowRoom(p)
 	})
-	if err := p.readLoop(); err != nil {
-		p.exit(err)
+}
+
+// conn is one of the user's connections to a player.  A player only has one
+// conn at a time.  When another connection takes over the player, the old
+// conn's goroutines finish before the new conn is attached, so they never see
+// the player's connection change under them.
+type conn struct {
+	user    *auth.User
+	queue   *cmdQueue     // commands waiting to be run
+	done    chan struct{} // closed once the conn's goroutines are finished with the player
+	exiting int32         // 1 once the player is leaving the world, accessed atomically
+
+	// moving is closed when the player's move to another zone is finished.  It
+	// is only used by the conn's own goroutines.
+	moving chan struct{}
+
+	// replaced is set when another connection takes over the player.  It is
+	// only used on the global worker.
+	replaced bool
+}
+
+// run runs the player's read loop on the connection until the player leaves
+// the world, loses their connection, or another connection takes over the
+// player.
+func (p *Player) run(c *conn) error {
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		p.runQueue(c)
+	}()
+	err := p.readLoop(c)
+	<-exited
+	p.settle()
+	close(c.done)
+	user := c.user
+	if atomic.LoadInt32(&c.exiting) == 1 || err == ErrSpam {
+		p.leave(c)
+		return nil
+	}
+	if err != nil {
+		logger.Info("lost connection", logging.Player(p.Name()), logging.User(user.Username), logging.Err(err), logging.Event("linkdead"))
+	} else {
+		logger.Info("lost connection", logging.Player(p.Name()), logging.User(user.Username), logging.Event("linkdead"))
 	}
+	p.loseLink(c)
 	return nil
 }
 
-func chooseDBPlayer(st *db.Store, user *auth.User) (*db.Player, error) {
+// attach points the player's input and output at the user's connection,
+// returning the new conn.  A failed write closes the connection, which ends the
+// read loop.  It must be run on the global worker if the player is already in
+// the world.
+func (p *Player) attach(user *auth.User) *conn {
+	var once sync.Once
+	p.User = user
+	p.SafeWriter = util.SafeWriter{Writer: user, OnErr: func(err error) {
+		once.Do(func() {
+			logger.Info("error writing to player", logging.Player(p.Name()), logging.User(user.Username), logging.Err(err))
+			user.Close()
+		})
+	}}
+	p.conn = &conn{
+		user:  user,
+		queue: p.world.newCmdQueue(),
+		done:  make(chan struct{}),
+	}
+	return p.conn
+}
+
+// reattach moves the player to a new connection from the same user, closing the
+// old connection, and returns the new conn.  The player stays where it is in
+// the world.  It returns nil if the player has already left the world.
+func (p *Player) reattach(user *auth.User) *conn {
+	var old *conn
+	replaced := make(chan struct{})
+	p.world.global.Handle(func() {
+		defer close(replaced)
+		if atomic.LoadInt32(&p.gone) == 1 {
+			return
+		}
+		old = p.conn
+		old.replaced = true
+		if !p.LinkDead() {
+			io.WriteString(old.user, "\nThis character has been taken over by another connection.\n")
+		}
+		old.user.Close()
+	})
+	<-replaced
+	if old == nil {
+		return nil
+	}
+	// The old conn's goroutines may still be running a command, so wait for
+	// them to finish before swapping the player's connection out from under
+	// them.  Their command may need the workers, so don't wait on one.
+	<-old.done
+
+	var c *conn
+	done := make(chan struct{})
+	p.world.global.Handle(func() {
+		defer close(done)
+		wasLinkDead := p.LinkDead()
+		if wasLinkDead {
+			logger.Info("reconnected to link-dead player", logging.User(user.Username), logging.Player(p.Name()), logging.Event("login"))
+			if p.linkdeadTimer != nil {
+				p.linkdeadTimer.Cancel()
+				p.linkdeadTimer = nil
+			}
+			atomic.StoreInt32(&p.linkdead, 0)
+			game.Publish(p.world.bus, LoggedIn{Player: p, Loc: p.Location(), Reconnect: true})
+		} else {
+			logger.Info("took over player from another connection", logging.User(user.Username), logging.Player(p.Name()), logging.Event("login"))
+		}
+		c = p.attach(user)
+		atomic.StoreInt32(&p.needsLF, 0)
+		atomic.StoreInt64(&p.lastInput, p.world.clock.Now().UnixNano())
+		for _, other := range p.Location().Players {
+			if !p.Is(other) {
+				if wasLinkDead {
+					other.Printf("%s has reconnected.", p.Name())
+				} else {
+					other.Printf("%s's eyes flicker as a new mind takes control.", p.Name())
+				}
+				other.prompt()
+			}
+		}
+		if wasLinkDead {
+			p.WriteString("Reconnecting to your character.\n")
+		} else {
+			p.WriteString("You take over your character.\n")
+		}
+		p.Location().ShowRoom(p)
+		p.prompt()
+	})
+	<-done
+	return c
+}
+
+func (w *World) chooseDBPlayer(st *db.Store, user *auth.User) (*db.Player, error) {
 	if len(user.Players) == 0 {
 		_, err := io.WriteString(user, "You have no players, let's create one.\n")
 		if err != nil {
 			return nil, err
 		}
-		return createPlayer(st, user, game.Genders)
+		return w.createPlayer(st, user, game.Genders)
 	}
 	if user.Flag(auth.UFlagAdmin) {
 		// Admins get exactly one player.
@@ -164,7 +366,7 @@ func chooseDBPlayer(st *db.Store, user *auth.User) (*db.Player, error) {
 		return nil, err
 	}
 	if i == 0 {
-		return createPlayer(st, user, game.Genders)
+		return w.createPlayer(st, user, game.Genders)
 	}
 	return st.FindPlayer(choices[i])
 }
@@ -187,7 +389,7 @@ func verifyName(name string) (string, error) {
 	return "", nil
 }
 
-func createPlayer(st *db.Store, user *auth.User, genders []game.Gender) (*db.Player, error) {
+func (w *World) createPlayer(st *db.Store, user *auth.User, genders []game.Gender) (*db.Player, error) {
 	const queryName = "By what name do you wish your character to be known? "
 	name, err := util.QueryVerify(user, queryName, verifyName)
 	if err != nil {
@@ -202,12 +404,24 @@ func createPlayer(st *db.Store, user *auth.User, genders []game.Gender) (*db.Pla
 		return nil, err
 	}
 	gender := genders[i]
+	race, class, err := w.chooseRace(user)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := w.rollAttrs(user, race.Modifiers, class.Modifiers)
+	if err != nil {
+		return nil, err
+	}
 	p := &db.Player{
 		Name:        name,
 		Description: name + " is standing here.",
 		Gender:      gender,
 		Flags:       big.NewInt(0),
+		Attributes:  attrs,
+		Race:        race.Name,
+		Class:       class.Name,
 	}
+	startFlags(p, race, class)
 	for {
 		err = st.CreatePlayer(user.Username, p)
 		if _, ok := err.(db.ErrExists); ok {
@@ -239,9 +453,8 @@ func (p *Player) Printf(format string, args ...interface{}) {
 var newline = []byte("\n")
 
 func (p *Player) maybeNewline() {
-	if p.needsLF {
+	if atomic.CompareAndSwapInt32(&p.needsLF, 1, 0) {
 		p.Writer.Write(newline)
-		p.needsLF = false
 	}
 }
 
@@ -276,7 +489,7 @@ func (p *Player) String() string {
 
 // HandleLocal runs the given event for the player on its zone-local thread.
 func (p *Player) HandleLocal(event func()) {
-	p.loc.Handle(func() {
+	p.Location().Area.Zone.HandleFrom(p, func() {
 		event()
 		p.prompt()
 	})
@@ -284,41 +497,120 @@ func (p *Player) HandleLocal(event func()) {
 
 // HandleGlobal runs the given event for the player on the global thread.
 func (p *Player) HandleGlobal(event func()) {
-	p.global.Handle(func() {
+	p.world.global.HandleFrom(p, func() {
 		event()
 		p.prompt()
 	})
 }
 
+// post runs the event on the worker for the player's zone during its next tick,
+// without waiting for it.  If the player moves to another zone before then,
+// the event follows them there.  Unlike HandleLocal, it is safe to call from
+// any worker, so it is how players in other zones are told things.
+func (p *Player) post(event func()) {
+	z := p.Location().Area.Zone
+	z.Post(func() {
+		if p.Location().Area.Zone != z {
+			p.post(event)
+			return
+		}
+		event()
+	})
+}
+
 // Move changes the player's location and adds the player to the location's map
 //
 // This is the function that does the heavy lifting for moving a player from one
 // room to another including keeping the user's location and the location map in
-// sync.  It will run on the appropriate thread depending on if this is a local
-// move or a move between zones.
+// sync.  Moves within a zone are run on the zone's worker.  Moves between zones
+// are handed off from one zone's worker to the other's, so the rest of the
+// world keeps running.
 func (p *Player) Move(to *Location) {
-	if to.ID == p.loc.ID {
+	from := p.Location()
+	if to.ID == from.ID {
 		return
 	}
 
-	if p.loc.LocalTo(to) {
+	if from.LocalTo(to) {
 		p.HandleLocal(func() {
 			p.Relocate(to)
 		})
-	} else {
-		p.HandleGlobal(func() {
-			p.Relocate(to)
+		return
+	}
+	p.handoff(from, to)
+}
+
+// handoff moves the player to a location in another zone in two steps.  First
+// the worker for the player's zone takes them out of their room and points them
+// at the new one, then the new zone's worker puts them in it.  In between, the
+// player is in neither room.  Events posted to the player during the move
+// follow them to the new zone, and the player's next command waits until they
+// have arrived.  If the player is moved by the global worker or leaves the
+// world in the meantime, the second step does nothing.
+func (p *Player) handoff(from, to *Location) {
+	moving := make(chan struct{})
+	p.conn.moving = moving
+	from.Area.Zone.HandleFrom(p, func() {
+		handedOff := false
+		defer func() {
+			if !handedOff {
+				close(moving)
+			}
+		}()
+		if p.Location() != from {
+			// moved by the global worker since the command was typed.
+			return
+		}
+		if to.Area.Zone.Closed() && !p.User.Flag(auth.UFlagAdmin) {
+			p.WriteString("That area is closed.")
+			p.prompt()
+			return
+		}
+		from.RemovePlayer(p)
+		p.loc.Store(to)
+		game.Publish(p.world.bus, PlayerLeft{Player: p, Loc: from, To: to})
+		handedOff = true
+		to.Area.Zone.Post(func() {
+			defer close(moving)
+			if atomic.LoadInt32(&p.gone) == 1 || p.Location() != to {
+				return
+			}
+			to.AddPlayer(p)
+			to.ShowRoom(p)
+			p.prompt()
+			game.Publish(p.world.bus, PlayerEntered{Player: p, Loc: to, From: from})
+			game.Publish(p.world.bus, Moved{Player: p, From: from, To: to})
 		})
+	})
+}
+
+// settle waits for the player to finish moving between zones, so the player's
+// next command is handled by the worker for the zone they ended up in.  It must
+// be called from the goroutines of the player's conn.
+func (p *Player) settle() {
+	if c := p.conn; c.moving != nil {
+		<-c.moving
+		c.moving = nil
 	}
 }
 
+// Failed implements game.Origin.  It tells the player that their command broke.
+func (p *Player) Failed() {
+	p.WriteString("\nSomething went wrong, and that couldn't be finished.  The admins have been notified.\n")
+	p.prompt()
+}
+
 // Relocate moves the character to a new lcoation. This is NOT run in a worker,
 // so you need to handle that yourself.
 func (p *Player) Relocate(to *Location) {
-	p.loc.RemovePlayer(p)
+	from := p.Location()
+	from.RemovePlayer(p)
 	to.AddPlayer(p)
-	p.loc = to
+	p.loc.Store(to)
 	to.ShowRoom(p)
+	game.Publish(p.world.bus, PlayerLeft{Player: p, Loc: from, To: to})
+	game.Publish(p.world.bus, PlayerEntered{Player: p, Loc: to, From: from})
+	game.Publish(p.world.bus, Moved{Player: p, From: from, To: to})
 }
 
 // Flag reports if the given flag has been set to true for the user.
@@ -336,19 +628,25 @@ func (p *Player) UnsetFlag(f PFlag) {
 	p.bits.SetBit(p.bits, int(f), 0)
 }
 
-// Location returns the user's location in the world.
+// World returns the world the player is in.
+func (p *Player) World() *World {
+	return p.world
+}
+
+// Location returns the user's location in the world.  It is safe to call from
+// any goroutine.
 func (p *Player) Location() *Location {
-	return p.loc
+	return p.loc.Load()
 }
 
 // exit removes the player from the world, logging the error if not nil.
 func (p *Player) exit(err error) {
 	if err != nil {
-		log.Printf("EXIT: Removing user %v from world. Error: %v", p, err)
+		logger.Error("removing player from world", logging.Player(p.Name()), logging.Err(err), logging.Event("logout"))
 	} else {
-		log.Printf("EXIT: Removing user %v from world.", p)
+		logger.Info("removing player from world", logging.Player(p.Name()), logging.Event("logout"))
 	}
-	p.exiting = true
+	atomic.StoreInt32(&p.conn.exiting, 1)
 }
 
 // Gender returns the player's gender.
@@ -356,42 +654,67 @@ func (p *Player) Gender() game.Gender {
 	return p.gender
 }
 
-// readLoop is a goroutine that just passes info from the player's input to the
-// runLoop.
-func (p *Player) readLoop() (err error) {
-	// need this because scan can panic if you send it too much stuff
-	// defer func() {
-	// 	panicErr := recover()
-	// 	if panicErr == nil {
-	// 		return
-	// 	}
-	// 	if e, ok := panicErr.(error); ok {
-	// 		err = e
-	// 		return
-	// 	}
-	// 	err = fmt.Errorf("%v", panicErr)
-	// }()
-	for p.Scan() {
-		// The user entered a command, so by definition has hit enter.
-		p.needsLF = false
-		p.handleCmd(p.Text())
-		if p.exiting {
-			p.HandleGlobal(func() {
-				p.loc.RemovePlayer(p)
-				removePlayer(p)
-			})
-			p.Close()
-			break
+// leave removes the player from the world, saves the player, and closes the
+// connection.  It does nothing to the player if another connection has taken
+// over the player, or the player has already been removed.
+func (p *Player) leave(c *conn) {
+	left := make(chan *db.Player, 1)
+	// intentionally directly call the global handler so we skip the autoprompt
+	// here.
+	p.world.global.Handle(func() {
+		if c.replaced || atomic.LoadInt32(&p.gone) == 1 {
+			// another connection took over the player, or the global worker
+			// already removed it, so this one just goes away quietly.
+			left <- nil
+			return
 		}
+		left <- p.remove()
+	})
+	c.user.Close()
+	if dbp := <-left; dbp != nil {
+		p.save(dbp)
+	}
+}
+
+// remove takes the player out of their location and the world, returning the
+// player's data to be saved.  It must be run on the global worker.
+func (p *Player) remove() *db.Player {
+	loc := p.Location()
+	atomic.StoreInt32(&p.gone, 1)
+	loc.RemovePlayer(p)
+	p.world.removePlayer(p)
+	game.Publish(p.world.bus, PlayerLeft{Player: p, Loc: loc})
+	game.Publish(p.world.bus, LoggedOut{Player: p, Loc: loc})
+	return p.snapshot()
+}
+
+// snapshot copies the player's data to be saved to the db.  It must be run on
+// the global worker.
+func (p *Player) snapshot() *db.Player {
+	return &db.Player{
+		Name:        p.name,
+		Description: p.Desc,
+		ID:          p.ID,
+		Gender:      p.gender,
+		Flags:       new(big.Int).Set(p.bits),
+		Attributes:  p.world.playerAttrs(p.attrs),
+		Race:        p.race,
+		Class:       p.class,
+	}
+}
+
+// save writes the player's data to the database.
+func (p *Player) save(dbp *db.Player) {
+	if err := p.st.SavePlayer(dbp); err != nil {
+		logger.Error("error saving player", logging.Player(p.Name()), logging.Err(err), logging.Event("save"))
 	}
-	return p.Err()
 }
 
 // prompt shows the player's prompt to the user.
 func (p *Player) prompt() {
 	// TODO: standard/custom prompts
 	io.WriteString(p.Writer, "\n>")
-	p.needsLF = true
+	atomic.StoreInt32(&p.needsLF, 1)
 }
 
 // reprompt shows the player's prompt to the user, but without the preceding
@@ -399,13 +722,16 @@ func (p *Player) prompt() {
 func (p *Player) reprompt() {
 	// TODO: standard/custom prompts
 	io.WriteString(p.Writer, ">")
-	p.needsLF = true
+	atomic.StoreInt32(&p.needsLF, 1)
 }
 
 // timeout times the player out of the world.
 func (p *Player) timeout() {
-	p.WriteString("You have timed out... good bye!")
+	p.WriteString("You have timed out... good bye!\n")
 	p.exit(ErrTimeout)
+	// closing the connection stops the read loop, which removes the player
+	// from the world.
+	p.Close()
 }
 
 // handleQuit asks the user if they really want to quit, and if they say yes,
@@ -428,17 +754,14 @@ func (p *Player) handleQuit() {
 // handleCmd converts tokens from the user into a Command object, and attempts
 // to handle it.  It reports whether the readloop should exit
 func (p *Player) handleCmd(s string) {
-	cmd := Command{Actor: p, Cmd: strings.Fields(s), Loc: p.loc}
+	atomic.AddInt64(&p.world.commandsRun, 1)
+	p.settle()
+	cmd := Command{World: p.world, Actor: p, Cmd: strings.Fields(s), Loc: p.Location()}
 	cmd.Handle()
 }
 
-// Query asks the player a question and receives an answer
+// Query asks the player a question and receives an answer.  If there's an
+// error, the connection is gone, and the read loop will clean up.
 func (p *Player) Query(q string) (answer string, err error) {
-	defer func() {
-		if err != nil {
-			p.exit(err)
-		}
-	}()
-
 	return util.Query(p, q)
 }
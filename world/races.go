@@ -0,0 +1,129 @@
+package world
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/util"
+)
+
+// initRaces checks and sets the races and classes players can choose.  It must
+// be run after the world is loaded, so start rooms can be checked.
+func (w *World) initRaces(races game.Races, classes game.Classes) error {
+	// copy before validating, since validating changes modifier names.
+	races = append(game.Races(nil), races...)
+	classes = append(game.Classes(nil), classes...)
+	if err := races.Validate(w.attributes); err != nil {
+		return err
+	}
+	if err := classes.Validate(w.attributes, races); err != nil {
+		return err
+	}
+	for _, r := range races {
+		if err := w.checkStart("race "+r.Name, r.StartRoom, r.Flags); err != nil {
+			return err
+		}
+	}
+	for _, c := range classes {
+		if err := w.checkStart("class "+c.Name, c.StartRoom, c.Flags); err != nil {
+			return err
+		}
+	}
+	w.races = races
+	w.classes = classes
+	return nil
+}
+
+// checkStart checks that a race or class's start room and flags exist.
+func (w *World) checkStart(what string, room int, flags []string) error {
+	if room != 0 {
+		if _, ok := w.Location(util.ID(room)); !ok {
+			return fmt.Errorf("%s starts in room %d, which doesn't exist", what, room)
+		}
+	}
+	for _, f := range flags {
+		if _, ok := pflagNames[strings.ToLower(f)]; !ok {
+			return fmt.Errorf("%s has unknown flag %q", what, f)
+		}
+	}
+	return nil
+}
+
+// chooseRace asks the user to choose a race and class for a new character.
+// Either may be empty if the MUD doesn't have them.
+func (w *World) chooseRace(user *auth.User) (game.Race, game.Class, error) {
+	var race game.Race
+	if len(w.races) > 0 {
+		options := make([]string, len(w.races))
+		for i, r := range w.races {
+			options[i] = describe(r.Name, r.Desc)
+		}
+		i, err := util.QueryStrings(user, "\nWhat race should this character be?\n", -1, options...)
+		if err != nil {
+			return game.Race{}, game.Class{}, err
+		}
+		race = w.races[i]
+	}
+	var class game.Class
+	if classes := w.classes.For(race.Name); len(classes) > 0 {
+		options := make([]string, len(classes))
+		for i, c := range classes {
+			options[i] = describe(c.Name, c.Desc)
+		}
+		i, err := util.QueryStrings(user, "\nWhat class should this character be?\n", -1, options...)
+		if err != nil {
+			return game.Race{}, game.Class{}, err
+		}
+		class = classes[i]
+	}
+	return race, class, nil
+}
+
+// describe returns a menu option for a race or class.
+func describe(name, desc string) string {
+	if desc == "" {
+		return name
+	}
+	return name + ": " + desc
+}
+
+// startFlags sets the flags a new character gets from their race and class.
+func startFlags(dbp *db.Player, race game.Race, class game.Class) {
+	for _, flags := range [][]string{race.Flags, class.Flags} {
+		for _, f := range flags {
+			dbp.Flags.SetBit(dbp.Flags, int(pflagNames[strings.ToLower(f)]), 1)
+		}
+	}
+}
+
+// Race returns the name of the player's race, or an empty string if they don't
+// have one.
+func (p *Player) Race() string {
+	return p.race
+}
+
+// Class returns the name of the player's class, or an empty string if they
+// don't have one.
+func (p *Player) Class() string {
+	return p.class
+}
+
+// startRoom returns where the player enters the world: their class's start
+// room, or else their race's, or else the MUD's.
+func (p *Player) startRoom() *Location {
+	w := p.world
+	var room int
+	if r, ok := w.races.Find(p.race); ok && r.StartRoom != 0 {
+		room = r.StartRoom
+	}
+	if c, ok := w.classes.Find(p.class); ok && c.StartRoom != 0 {
+		room = c.StartRoom
+	}
+	if loc, ok := w.Location(util.ID(room)); ok {
+		return loc
+	}
+	return w.Start()
+}
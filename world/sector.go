@@ -0,0 +1,51 @@
+package world
+
+import "fmt"
+
+// Sector is the kind of terrain a location has.
+type Sector string
+
+// All the sectors.
+const (
+	SectorCity        Sector = "CITY"
+	SectorDesert      Sector = "DESERT"
+	SectorField       Sector = "FIELD"
+	SectorFlying      Sector = "FLYING"
+	SectorForest      Sector = "FOREST"
+	SectorHills       Sector = "HILLS"
+	SectorInside      Sector = "INSIDE"
+	SectorMountain    Sector = "MOUNTAIN"
+	SectorRoad        Sector = "ROAD"
+	SectorUnderwater  Sector = "UNDERWATER"
+	SectorWaterNoSwim Sector = "WATER_NOSWIM"
+	SectorWaterSwim   Sector = "WATER_SWIM"
+)
+
+// sectorWetness is how much each sector pushes a zone's weather toward rain
+// and storms.  Sectors that never see the sky don't count toward a zone's
+// weather at all.
+var sectorWetness = map[Sector]int{
+	SectorCity:        0,
+	SectorDesert:      -3,
+	SectorField:       0,
+	SectorFlying:      0,
+	SectorForest:      1,
+	SectorHills:       1,
+	SectorMountain:    2,
+	SectorRoad:        0,
+	SectorWaterNoSwim: 1,
+	SectorWaterSwim:   1,
+}
+
+// parseSector returns the sector with the given name.  Rooms without a sector
+// are inside.
+func parseSector(s string) (Sector, error) {
+	if s == "" {
+		return SectorInside, nil
+	}
+	sec := Sector(s)
+	if _, ok := sectorWetness[sec]; ok || sec == SectorInside || sec == SectorUnderwater {
+		return sec, nil
+	}
+	return "", fmt.Errorf("unknown sector %q", s)
+}
@@ -0,0 +1,260 @@
+package world
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/natefinch/claymud/auth"
+	"github.com/natefinch/claymud/db"
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/util"
+)
+
+// Stop is a request from an admin to stop the MUD.
+type Stop struct {
+	Reboot bool   // whether the MUD should start back up again
+	Reason string // why the MUD is stopping, may be empty
+	By     string // the admin that asked for the stop
+}
+
+// Handoff is a player whose connection can be handed to the new process when
+// the MUD reboots.
+type Handoff struct {
+	Username string
+	Player   string
+	Room     util.ID
+	Conn     io.ReadWriteCloser
+	User     *auth.User // for output that goes through the player's buffer
+}
+
+// warnings are the times before the stop when everyone gets a reminder.  Before
+// the first one, they get a reminder every 5 minutes.
+var warnings = []time.Duration{
+	5 * time.Minute,
+	4 * time.Minute,
+	3 * time.Minute,
+	2 * time.Minute,
+	time.Minute,
+	30 * time.Second,
+	10 * time.Second,
+}
+
+// Stops returns the channel that receives a Stop when an admin has shut down or
+// rebooted the MUD and all players have been saved.
+func (w *World) Stops() <-chan Stop {
+	return w.stops
+}
+
+// stopCountdown counts down to a shutdown or reboot.  It is only used on the
+// global worker.
+type stopCountdown struct {
+	Stop
+	at    time.Time   // when the stop happens, on the world's clock
+	timer *game.Timer // fires for the next reminder, or for the stop itself
+}
+
+// what returns what the countdown is counting down to.
+func (s *stopCountdown) what() string {
+	if s.Reboot {
+		return "reboot"
+	}
+	return "shutdown"
+}
+
+// schedule sets the timer for the next reminder, or for the stop if there are
+// no reminders left.  It must be run on the global worker.
+func (s *stopCountdown) schedule(w *World) {
+	left := s.at.Sub(w.clock.Now())
+	next := nextWarning(left)
+	s.timer = w.global.After(left-next, func() {
+		if w.countdown != s {
+			// cancelled, don't remind anyone of a stop that isn't coming.
+			return
+		}
+		if next == 0 {
+			w.stop(s)
+			return
+		}
+		w.broadcast(s.announce(next))
+		s.schedule(w)
+	})
+}
+
+// stop saves everyone and sends the stop request.  It must be run on the global
+// worker.
+func (w *World) stop(s *stopCountdown) {
+	w.countdown = nil
+	if s.Reboot {
+		w.broadcast("The MUD is rebooting now, hold on tight!")
+	} else {
+		w.broadcast("The MUD is shutting down now.  Goodbye!")
+	}
+	saves := make([]playerSave, 0, w.players.len())
+	for _, p := range w.players.list() {
+		saves = append(saves, playerSave{p: p, dbp: p.snapshot()})
+	}
+	logger.Info("saving players", "count", len(saves), "before", s.what(), logging.Event("save"))
+	// don't hold up the worker writing to the db.
+	go func() {
+		for _, ps := range saves {
+			ps.p.save(ps.dbp)
+		}
+		w.stops <- s.Stop
+	}()
+}
+
+// playerSave is a player's data to be saved to the db.
+type playerSave struct {
+	p   *Player
+	dbp *db.Player
+}
+
+// announce returns the message telling everyone how long until the stop.
+func (s *stopCountdown) announce(left time.Duration) string {
+	msg := fmt.Sprintf("The MUD will %s in %s.", s.what(), howLong(left))
+	if s.Reason != "" {
+		msg += "  Reason: " + s.Reason
+	}
+	return msg
+}
+
+// nextWarning returns how long before the stop the next reminder should be sent.
+func nextWarning(left time.Duration) time.Duration {
+	if left > 10*time.Minute {
+		return (left - 1) / (5 * time.Minute) * (5 * time.Minute)
+	}
+	for _, w := range warnings {
+		if w < left {
+			return w
+		}
+	}
+	return 0
+}
+
+// howLong returns a friendly version of a countdown duration.
+func howLong(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "1 minute"
+	case d > time.Minute:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return fmt.Sprintf("%d seconds", d/time.Second)
+	}
+}
+
+// Announce sends the message to everyone in the world.
+func (w *World) Announce(msg string) {
+	w.global.Handle(func() {
+		w.broadcast(msg)
+	})
+}
+
+// broadcast sends the message to everyone in the world.  It must be run on the
+// global worker.
+func (w *World) broadcast(msg string) {
+	w.broadcastFrom(nil, msg)
+}
+
+// broadcastFrom sends the message to everyone in the world.  The actor, who
+// gets prompted after their command runs, is not prompted here.  It must be run
+// on the global worker.
+func (w *World) broadcastFrom(actor *Player, msg string) {
+	for _, p := range w.players.list() {
+		p.WriteString("\n*** " + msg + " ***\n")
+		if actor == nil || !p.Is(actor) {
+			p.prompt()
+		}
+	}
+}
+
+// Handoffs returns the players that are connected, along with the room they are
+// in.
+func (w *World) Handoffs() []Handoff {
+	done := make(chan []Handoff)
+	w.global.Handle(func() {
+		var hs []Handoff
+		for _, p := range w.players.list() {
+			if p.LinkDead() {
+				continue
+			}
+			hs = append(hs, Handoff{
+				Username: p.Username,
+				Player:   p.Name(),
+				Room:     p.Location().ID,
+				Conn:     p.User.Conn(),
+				User:     p.User,
+			})
+		}
+		done <- hs
+	})
+	return <-done
+}
+
+func shutdownCmd(c *Command) {
+	stopCmd(c, false)
+}
+
+func reboot(c *Command) {
+	stopCmd(c, true)
+}
+
+// stopCmd handles the shutdown and reboot commands, which look like
+// "shutdown [minutes] [reason]" or "shutdown cancel".
+func stopCmd(c *Command, reboot bool) {
+	if !c.requireAdmin() {
+		return
+	}
+	args := strings.Fields(c.Text(false))
+	var minutes int
+	w := c.World
+	if len(args) > 0 {
+		if strings.EqualFold(args[0], "cancel") {
+			c.Actor.HandleGlobal(func() {
+				if w.countdown == nil {
+					c.Actor.WriteString("Nothing is scheduled.\n")
+					return
+				}
+				w.countdown.timer.Cancel()
+				w.broadcastFrom(c.Actor, fmt.Sprintf("The %s has been cancelled.", w.countdown.what()))
+				logger.Info("cancelled "+w.countdown.what(), logging.Player(c.Actor.Name()), logging.Event(w.countdown.what()))
+				w.countdown = nil
+			})
+			return
+		}
+		if m, err := strconv.Atoi(args[0]); err == nil {
+			if m < 0 {
+				c.Actor.HandleLocal(func() {
+					c.Actor.WriteString("The number of minutes can't be negative.\n")
+				})
+				return
+			}
+			minutes = m
+			args = args[1:]
+		}
+	}
+	c.Actor.HandleGlobal(func() {
+		if w.countdown != nil {
+			c.Actor.Printf("A %s is already scheduled.  Cancel it first.\n", w.countdown.what())
+			return
+		}
+		s := &stopCountdown{
+			Stop: Stop{
+				Reboot: reboot,
+				Reason: strings.Join(args, " "),
+				By:     c.Actor.Name(),
+			},
+			at: w.clock.Now().Add(time.Duration(minutes) * time.Minute),
+		}
+		w.countdown = s
+		logger.Info("scheduled "+s.what(), logging.Player(s.By), "minutes", minutes, "reason", s.Reason, logging.Event(s.what()))
+		if minutes > 0 {
+			w.broadcastFrom(c.Actor, s.announce(time.Duration(minutes)*time.Minute))
+		}
+		s.schedule(w)
+	})
+}
@@ -0,0 +1,120 @@
+package world
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+)
+
+func TestNextWarning(t *testing.T) {
+	tests := []struct {
+		left     time.Duration
+		expected time.Duration
+	}{
+		{left: 60 * time.Minute, expected: 55 * time.Minute},
+		{left: 12 * time.Minute, expected: 10 * time.Minute},
+		{left: 10 * time.Minute, expected: 5 * time.Minute},
+		{left: 5 * time.Minute, expected: 4 * time.Minute},
+		{left: 90 * time.Second, expected: time.Minute},
+		{left: time.Minute, expected: 30 * time.Second},
+		{left: 10 * time.Second, expected: 0},
+	}
+	for _, test := range tests {
+		if got := nextWarning(test.left); got != test.expected {
+			t.Errorf("nextWarning(%v): expected %v, got %v", test.left, test.expected, got)
+		}
+	}
+}
+
+func TestStopCountdown(t *testing.T) {
+	clock := game.NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.Manual = true
+		cfg.Clock = clock
+	})
+	var stepMu sync.Mutex
+	defer h.keepStepping(&stepMu)()
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+
+	// settle waits for the global worker to run a full tick, so any timers due
+	// by now have fired.
+	settle := func() {
+		for i := 0; i < 2; i++ {
+			done := make(chan struct{})
+			h.w.global.Handle(func() { close(done) })
+			<-done
+		}
+	}
+
+	alice.Send("shutdown -1")
+	alice.Expect("The number of minutes can't be negative.")
+	alice.Expect(">")
+
+	alice.Send("shutdown 6 testing")
+	bob.Expect("The MUD will shutdown in 6 minutes.  Reason: testing")
+	clock.Advance(time.Minute)
+	bob.Expect("The MUD will shutdown in 5 minutes.  Reason: testing")
+	clock.Advance(time.Minute)
+	bob.Expect("The MUD will shutdown in 4 minutes.  Reason: testing")
+
+	alice.Send("shutdown cancel")
+	bob.Expect("The shutdown has been cancelled.")
+	clock.Advance(10 * time.Minute)
+	settle()
+	alice.Send("say still here")
+	if before := bob.Expect("Alice: still here"); strings.Contains(before, "The MUD will") {
+		t.Errorf("expected no reminders after the cancel, got:\n%s", before)
+	}
+	select {
+	case stop := <-h.w.Stops():
+		t.Fatalf("expected the cancelled shutdown not to stop the MUD, got %+v", stop)
+	default:
+	}
+
+	alice.Send("reboot 1")
+	bob.Expect("The MUD will reboot in 1 minute.")
+	clock.Advance(30 * time.Second)
+	bob.Expect("The MUD will reboot in 30 seconds.")
+	clock.Advance(20 * time.Second)
+	bob.Expect("The MUD will reboot in 10 seconds.")
+	clock.Advance(10 * time.Second)
+	bob.Expect("The MUD is rebooting now, hold on tight!")
+	select {
+	case stop := <-h.w.Stops():
+		if !stop.Reboot || stop.By != "Alice" {
+			t.Errorf("expected a reboot by Alice, got %+v", stop)
+		}
+	case <-time.After(expectTimeout):
+		t.Fatal("the reboot never happened")
+	}
+}
+
+func TestHandoffsSkipLinkDead(t *testing.T) {
+	h := newHarnessWith(t, func(cfg *Config) {
+		cfg.LinkDeadGrace = time.Hour
+	})
+	defer h.close()
+	alice := h.connect("Alice")
+	bob := h.connect("Bob")
+	bob.conn.Close()
+	alice.Expect("Bob has lost their link.")
+	alice.Send("north")
+	alice.Expect("Windy Alley")
+
+	// Bob's connection is gone, so there's nothing of Bob's to hand off.
+	hs := h.w.Handoffs()
+	if len(hs) != 1 {
+		t.Fatalf("expected only Alice to be handed off, got %+v", hs)
+	}
+	if hs[0].Username != "alice" || hs[0].Player != "Alice" || hs[0].Room != 101 {
+		t.Errorf("unexpected handoff for Alice: %+v", hs[0])
+	}
+	if hs[0].User == nil || hs[0].Conn != hs[0].User.Conn() {
+		t.Errorf("expected Alice's handoff to have Alice's connection, got %+v", hs[0])
+	}
+}
@@ -0,0 +1,26 @@
+package world
+
+import "time"
+
+// Status is a snapshot of the state of the world.
+type Status struct {
+	Players int
+	Zones   int
+	Rooms   int
+	Mobs    int
+	Started time.Time
+}
+
+// Status returns the current status of the world.  It is safe to call from any
+// goroutine.
+func (w *World) Status() Status {
+	// zones, rooms, and mobs are only written during Init, so it's safe to read
+	// them here.
+	return Status{
+		Players: w.players.len(),
+		Zones:   len(w.allZones),
+		Rooms:   len(w.locMap),
+		Mobs:    len(w.allMobs),
+		Started: w.started,
+	}
+}
@@ -0,0 +1,212 @@
+package world
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/game/social"
+)
+
+// timeEpoch is the real time when game time began.  Game time is worked out
+// from the clock, so it carries on where it left off after a reboot.
+var timeEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// maxCatchUp is the most game hours passTime will run through at once, so a
+// world that was stalled for a long time doesn't flood players with sunrises.
+const maxCatchUp = 24
+
+// initTime sets up the calendar and each zone's weather.  It must be run after
+// the world is loaded.
+func (w *World) initTime(cal game.Calendar) error {
+	if cal.HourLength == 0 {
+		cal = game.DefaultCalendar
+	}
+	if err := cal.Validate(); err != nil {
+		return err
+	}
+	w.calendar = &cal
+	atomic.StoreInt64(&w.hours, w.gameHours())
+	for _, z := range w.allZones {
+		z.initWeather()
+	}
+	return nil
+}
+
+// gameHours returns how many game hours have passed on the world's clock.
+func (w *World) gameHours() int64 {
+	return int64(w.clock.Now().Sub(timeEpoch) / w.calendar.HourLength)
+}
+
+// Time returns the current game time.  It is safe to call from any goroutine.
+func (w *World) Time() game.Time {
+	return w.calendar.Time(atomic.LoadInt64(&w.hours))
+}
+
+// watchTime passes game time on the global worker, at the start of every game
+// hour.
+func (w *World) watchTime() {
+	next := timeEpoch.Add(time.Duration(w.gameHours()+1) * w.calendar.HourLength)
+	w.global.After(next.Sub(w.clock.Now()), func() {
+		w.passTime()
+		w.global.Every(w.calendar.HourLength, w.passTime)
+	})
+}
+
+// passTime moves game time up to the clock's current hour.  It must be run on
+// the global worker.
+func (w *World) passTime() {
+	now := w.gameHours()
+	if now-atomic.LoadInt64(&w.hours) > maxCatchUp {
+		atomic.StoreInt64(&w.hours, now-maxCatchUp)
+	}
+	w.advanceTime(now)
+}
+
+// advanceTime moves game time up to the given hour, and tells each zone about
+// every hour that passed.  It must be run on the global worker.
+func (w *World) advanceTime(to int64) {
+	for h := atomic.LoadInt64(&w.hours); h < to; {
+		h++
+		atomic.StoreInt64(&w.hours, h)
+		t := w.calendar.Time(h)
+		for _, z := range w.sortedZones() {
+			z := z
+			z.Post(func() { z.passHour(t) })
+		}
+	}
+}
+
+// initWeather works out the zone's climate from its locations' sectors and
+// remembers which of its locations are outdoors.
+func (z *Zone) initWeather() {
+	var total, count int
+	for _, a := range z.Areas {
+		for _, l := range a.Locations {
+			if !l.Outdoors() {
+				continue
+			}
+			z.outdoors = append(z.outdoors, l)
+			if wet, ok := sectorWetness[l.Sector]; ok {
+				total += wet
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		z.wetness = total / count
+	}
+}
+
+// Weather returns the zone's weather.  It must be called on the zone's worker.
+func (z *Zone) Weather() game.Weather {
+	return z.weather
+}
+
+// passHour tells the zone's outdoor players about the sun coming up or going
+// down, and changes the weather.  It must be run on the zone's worker.
+func (z *Zone) passHour(t game.Time) {
+	var sun string
+	switch {
+	case t.Sunrise():
+		sun = "The sun rises in the east.\n"
+	case t.Sunset():
+		sun = "The sun slowly disappears in the west.\n"
+	}
+	old := z.weather
+	z.weather = old.Next(z.world.rng, z.wetness+t.Season().Wetness)
+	change := weatherChange(old, z.weather)
+	if sun == "" && change == "" {
+		return
+	}
+	for _, l := range z.outdoors {
+		msg := sun
+		if l.Sector != SectorUnderwater {
+			msg += change
+		}
+		if msg == "" {
+			continue
+		}
+		for _, p := range l.Players {
+			p.WriteString(msg)
+			p.prompt()
+		}
+	}
+}
+
+// weatherChange returns the message outdoor players get when the weather
+// changes, or an empty string if it didn't.
+func weatherChange(from, to game.Weather) string {
+	switch {
+	case from == to:
+		return ""
+	case to == game.WeatherCloudy && from == game.WeatherClear:
+		return "The sky grows dark with clouds.\n"
+	case to == game.WeatherRain && from == game.WeatherCloudy:
+		return "It starts to rain.\n"
+	case to == game.WeatherStorm:
+		return "Lightning flashes as a storm breaks overhead.\n"
+	case to == game.WeatherRain:
+		return "The storm dies down to a steady rain.\n"
+	case to == game.WeatherCloudy:
+		return "The rain stops.\n"
+	default:
+		return "The clouds drift away.\n"
+	}
+}
+
+// Outdoors reports whether the location is open to the sky.
+func (l *Location) Outdoors() bool {
+	return !l.Flags["INDOORS"]
+}
+
+// Time returns the current game time.
+func (l *Location) Time() game.Time {
+	return l.World().Time()
+}
+
+// Weather returns the weather in the location's zone, whether or not the
+// location is outdoors.
+func (l *Location) Weather() game.Weather {
+	return l.Area.Zone.weather
+}
+
+// Sky describes the sky as seen from the location, or returns an empty string
+// if the sky can't be seen from there.
+func (l *Location) Sky() string {
+	if !l.Outdoors() || l.Sector == SectorUnderwater {
+		return ""
+	}
+	day := l.Time().Daytime()
+	switch l.Weather() {
+	case game.WeatherCloudy:
+		if day {
+			return "Grey clouds hide the sun."
+		}
+		return "Clouds hide the stars."
+	case game.WeatherRain:
+		if day {
+			return "Rain falls from a grey sky."
+		}
+		return "Rain falls through the darkness."
+	case game.WeatherStorm:
+		if day {
+			return "Thunder rumbles as rain lashes down."
+		}
+		return "Lightning splits the night sky as rain lashes down."
+	default:
+		if day {
+			return "The sun shines down from a clear sky."
+		}
+		return "Stars glitter in the clear night sky."
+	}
+}
+
+// setting returns the time and weather at the location for socials.
+func (l *Location) setting() social.Setting {
+	return social.Setting{
+		Time:     l.Time(),
+		Weather:  l.Weather(),
+		Outdoors: l.Outdoors(),
+	}
+}
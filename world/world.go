@@ -0,0 +1,143 @@
+package world
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/natefinch/claymud/game"
+	"github.com/natefinch/claymud/logging"
+	"github.com/natefinch/claymud/util"
+)
+
+var (
+	logger       = logging.For("world")
+	scriptLogger = logging.For("scripts")
+)
+
+// World is a complete MUD world: its zones, rooms, and mobs, the players in it,
+// and the workers that run it.  Worlds share nothing with each other, so more
+// than one can run in the same process, such as a test server alongside the
+// real one.
+type World struct {
+	locMap   map[util.ID]*Location
+	allZones map[util.ID]*Zone
+	allMobs  map[util.ID]*Mob
+	start    *Location
+
+	// runLock keeps the global worker from running at the same time as the
+	// zone workers.
+	runLock *sync.RWMutex
+	global  *game.Worker
+
+	// players can be used from any goroutine.  Players are only added and
+	// removed on the global worker.
+	players *directory
+
+	// countdown is only accessed on the global worker.
+	countdown *stopCountdown
+
+	commands     map[string]func(*Command)
+	allCommands  []CommandCfg
+	clearNames   map[string]bool
+	helptext     string
+	movementHelp string
+	socialsHelp  string
+
+	chatMode      ChatMode
+	idleCfg       Idle
+	voidRoom      *Location
+	linkDeadGrace time.Duration
+	inputCfg      Input
+
+	clock         game.Clock
+	rng           *game.Rand
+	calendar      *game.Calendar
+	started       time.Time
+	locTemplate   *template.Template
+	scoreTemplate *template.Template
+	attributes    game.Attributes
+	races         game.Races
+	classes       game.Classes
+	actionDir     string
+
+	stops chan Stop
+	bus   *game.Bus
+
+	hours       int64 // game hours since game time began, accessed atomically
+	commandsRun int64 // commands players have typed, accessed atomically
+	commandRate int64 // commands run in the last second, accessed atomically
+
+	sent util.Counter // bytes of output sent to players
+}
+
+// newWorld returns an empty world.
+func newWorld() *World {
+	return &World{
+		locMap:     map[util.ID]*Location{},
+		allZones:   map[util.ID]*Zone{},
+		allMobs:    map[util.ID]*Mob{},
+		runLock:    &sync.RWMutex{},
+		players:    newDirectory(),
+		commands:   map[string]func(*Command){},
+		clearNames: map[string]bool{},
+		clock:      game.SystemClock,
+		stops:      make(chan Stop, 1),
+		bus:        game.NewBus(),
+	}
+}
+
+// Location returns the location with the given ID, if it exists.  It is safe to
+// call from any goroutine.
+func (w *World) Location(id util.ID) (*Location, bool) {
+	loc, ok := w.locMap[id]
+	return loc, ok
+}
+
+// setStart sets the starting room of the mud.
+func (w *World) setStart(room util.ID) error {
+	loc, exists := w.locMap[room]
+	if !exists {
+		return fmt.Errorf("starting room %v does not exist", room)
+	}
+	w.start = loc
+	return nil
+}
+
+// Start returns the start room of the MUD, where players appear
+// TODO: multiple / configurable start rooms
+func (w *World) Start() *Location {
+	return w.start
+}
+
+// Step runs a single tick of a world created with Config.Manual.  It steps the
+// global worker and then every zone in order of ID, so a run with the same
+// seed does the same thing every time.
+func (w *World) Step() {
+	w.global.Step()
+	for _, z := range w.sortedZones() {
+		z.Step()
+	}
+}
+
+// Simulate runs n ticks of a world created with Config.Manual as fast as
+// possible, advancing the clock by a tick before each one.  The clock must be
+// the world's clock.
+func (w *World) Simulate(clock *game.ManualClock, n int) {
+	for i := 0; i < n; i++ {
+		clock.Advance(game.TickLen)
+		w.Step()
+	}
+}
+
+// sortedZones returns all the zones ordered by ID.
+func (w *World) sortedZones() []*Zone {
+	zones := make([]*Zone, 0, len(w.allZones))
+	for _, z := range w.allZones {
+		zones = append(zones, z)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].ID < zones[j].ID })
+	return zones
+}